@@ -0,0 +1,91 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+var spanAttributesSpec = insertSpec{
+	table: "span_attributes",
+	columns: []string{
+		"trace_id",
+		"span_id",
+		"key",
+		"type",
+		"str_value",
+		"int_value",
+		"double_value",
+		"bool_value",
+	},
+	values: []string{
+		"?", "?", "?", "?", "?", "?", "?", "?",
+	},
+}
+
+// attributeIndex selects the span attributes written to the span_attributes
+// table.
+type attributeIndex struct {
+	// keys are the indexed keys, every key is indexed when nil.
+	keys map[string]struct{}
+
+	// max is the maximum number of attributes indexed per span, 0 removes the
+	// limit.
+	max int
+}
+
+// newAttributeIndex returns the attribute index of the config, or nil if it
+// isn't enabled.
+func newAttributeIndex(cfg AttributeIndexConfig) *attributeIndex {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	idx := &attributeIndex{max: cfg.MaxAttributesPerSpan}
+	if len(cfg.Keys) > 0 {
+		idx.keys = make(map[string]struct{}, len(cfg.Keys))
+		for _, k := range cfg.Keys {
+			idx.keys[k] = struct{}{}
+		}
+	}
+	return idx
+}
+
+// add writes a row for every indexed attribute of a span. Only strings,
+// integers, doubles and bools are indexed, attributes of any other type are
+// skipped and don't count towards the limit.
+func (idx *attributeIndex) add(ctx context.Context, rows *batchInserter, traceID, spanID []byte, attrs pcommon.Map) error {
+	var err error
+	indexed := 0
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if idx.max > 0 && indexed >= idx.max {
+			return false
+		}
+		if idx.keys != nil {
+			if _, ok := idx.keys[k]; !ok {
+				return true
+			}
+		}
+
+		var typ string
+		var str, i, f, b any
+		switch v.Type() {
+		case pcommon.ValueTypeStr:
+			typ, str = "string", v.Str()
+		case pcommon.ValueTypeInt:
+			typ, i = "int", v.Int()
+		case pcommon.ValueTypeDouble:
+			typ, f = "double", v.Double()
+		case pcommon.ValueTypeBool:
+			typ, b = "bool", v.Bool()
+		default:
+			return true
+		}
+
+		indexed++
+		err = rows.add(ctx, traceID, spanID, k, typ, str, i, f, b)
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func Test_ExporterAttributeIndex(t *testing.T) {
+	ctx := context.Background()
+
+	traces := ptrace.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{0x01})
+	span.SetSpanID(pcommon.SpanID{0x01})
+	span.Attributes().PutStr("user.id", "42")
+	span.Attributes().PutInt("http.status_code", 200)
+	span.Attributes().PutDouble("ratio", 0.5)
+	span.Attributes().PutBool("sampled", true)
+	span.Attributes().PutEmptySlice("tags").AppendEmpty().SetStr("a")
+
+	tests := []struct {
+		name     string
+		cfg      AttributeIndexConfig
+		expected [][]any
+	}{
+		{
+			name:     "disabled",
+			cfg:      AttributeIndexConfig{},
+			expected: nil,
+		},
+		{
+			// slices aren't indexed.
+			name: "every key",
+			cfg:  AttributeIndexConfig{Enabled: true},
+			expected: [][]any{
+				{"http.status_code", "int", nil, int64(200), nil, nil},
+				{"ratio", "double", nil, nil, 0.5, nil},
+				{"sampled", "bool", nil, nil, nil, int64(1)},
+				{"user.id", "string", "42", nil, nil, nil},
+			},
+		},
+		{
+			name: "keys",
+			cfg:  AttributeIndexConfig{Enabled: true, Keys: []string{"user.id", "tags", "missing"}},
+			expected: [][]any{
+				{"user.id", "string", "42", nil, nil, nil},
+			},
+		},
+		{
+			name: "max attributes per span",
+			cfg:  AttributeIndexConfig{Enabled: true, MaxAttributesPerSpan: 2},
+			expected: [][]any{
+				{"http.status_code", "int", nil, int64(200), nil, nil},
+				{"user.id", "string", "42", nil, nil, nil},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Path = filepath.Join(t.TempDir(), "index.db")
+			cfg.AttributeIndex = tt.cfg
+
+			ex, err := newSqliteExporter(cfg)
+			require.NoError(t, err)
+			defer ex.Shutdown(ctx)
+			require.NoError(t, ex.ConsumeTraces(ctx, traces))
+			// sending the same span again is a no-op.
+			require.NoError(t, ex.ConsumeTraces(ctx, traces))
+
+			rows, err := ex.db.Query("select key, type, str_value, int_value, double_value, bool_value from span_attributes order by key;")
+			require.NoError(t, err)
+			defer rows.Close()
+			var got [][]any
+			for rows.Next() {
+				var key, typ string
+				var str, i, f, b any
+				require.NoError(t, rows.Scan(&key, &typ, &str, &i, &f, &b))
+				if s, ok := str.([]byte); ok {
+					str = string(s)
+				}
+				got = append(got, []any{key, typ, str, i, f, b})
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func Test_spanAttributesIndexed(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = filepath.Join(t.TempDir(), "index.db")
+
+	ex, err := newSqliteExporter(cfg)
+	require.NoError(t, err)
+	defer ex.Shutdown(context.Background())
+
+	for col, idx := range map[string]string{
+		"str_value":    "span_attributes_key_str_value_idx",
+		"int_value":    "span_attributes_key_int_value_idx",
+		"double_value": "span_attributes_key_double_value_idx",
+		"bool_value":   "span_attributes_key_bool_value_idx",
+	} {
+		rows, err := ex.db.Query("explain query plan select trace_id, span_id from span_attributes where key = ? and "+col+" = ?;", "k", 1)
+		require.NoError(t, err)
+		var plan []string
+		for rows.Next() {
+			var id, parent, unused int
+			var detail string
+			require.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+			plan = append(plan, detail)
+		}
+		require.NoError(t, rows.Err())
+		rows.Close()
+		assert.Contains(t, strings.Join(plan, "\n"), "INDEX "+idx, col)
+	}
+}
+
+func Test_ExporterPruneAttributeIndex(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+	ex.attributeIndex = newAttributeIndex(AttributeIndexConfig{Enabled: true})
+	require.NoError(t, ex.ConsumeTraces(ctx, oldTraces(0, 5, now.Add(-2*time.Hour))))
+	require.NoError(t, ex.ConsumeTraces(ctx, oldTraces(1, 3, now.Add(-time.Minute))))
+	assert.Equal(t, 16, countRows(t, db, "span_attributes"))
+
+	require.NoError(t, ex.prune(ctx, now))
+
+	assert.Equal(t, 3, countRows(t, db, "spans"))
+	assert.Equal(t, 6, countRows(t, db, "span_attributes"))
+}
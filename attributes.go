@@ -0,0 +1,139 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// marshalAttributes encodes the attributes with the configured attribute
+// encoding.
+func (e *sqliteExporter) marshalAttributes(m pcommon.Map) ([]byte, error) {
+	if e.attributeEncoding == AttributeEncodingOTLPJSON {
+		return otlpMapAsJSON(m)
+	}
+	return pcommonMapAsJSON(m)
+}
+
+// otlpMapAsJSON encodes m as a JSON object mapping every key to its value in
+// the OTLP AnyValue JSON encoding, e.g. {"http.status_code":{"intValue":"200"}}.
+// Keys are kept at the top level, rather than in a list of key-value pairs
+// like OTLP does, so a single attribute can still be read with json_extract.
+func otlpMapAsJSON(m pcommon.Map) ([]byte, error) {
+	b := make([]byte, 0, 64*m.Len())
+	b = append(b, '{')
+	first := true
+	var err error
+	m.Range(func(k string, v pcommon.Value) bool {
+		if !first {
+			b = append(b, ',')
+		}
+		first = false
+
+		if b, err = appendJSONString(b, k); err != nil {
+			return false
+		}
+		b = append(b, ':')
+		b, err = appendAnyValue(b, v)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// appendAnyValue appends v in the OTLP AnyValue JSON encoding. As in the
+// protobuf JSON mapping, 64-bit integers are encoded as strings, and doubles
+// that can't be represented as JSON numbers as NaN, Infinity or -Infinity.
+func appendAnyValue(b []byte, v pcommon.Value) ([]byte, error) {
+	var err error
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		b = append(b, `{"stringValue":`...)
+		if b, err = appendJSONString(b, v.Str()); err != nil {
+			return nil, err
+		}
+	case pcommon.ValueTypeBool:
+		b = append(b, `{"boolValue":`...)
+		b = strconv.AppendBool(b, v.Bool())
+	case pcommon.ValueTypeInt:
+		b = append(b, `{"intValue":"`...)
+		b = strconv.AppendInt(b, v.Int(), 10)
+		b = append(b, '"')
+	case pcommon.ValueTypeDouble:
+		b = append(b, `{"doubleValue":`...)
+		b = appendDouble(b, v.Double())
+	case pcommon.ValueTypeBytes:
+		b = append(b, `{"bytesValue":"`...)
+		b = append(b, base64.StdEncoding.EncodeToString(v.Bytes().AsRaw())...)
+		b = append(b, '"')
+	case pcommon.ValueTypeSlice:
+		b = append(b, `{"arrayValue":{"values":[`...)
+		s := v.Slice()
+		for i := 0; i < s.Len(); i++ {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendAnyValue(b, s.At(i)); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, "]}"...)
+	case pcommon.ValueTypeMap:
+		b = append(b, `{"kvlistValue":{"values":[`...)
+		first := true
+		v.Map().Range(func(k string, v pcommon.Value) bool {
+			if !first {
+				b = append(b, ',')
+			}
+			first = false
+
+			b = append(b, `{"key":`...)
+			if b, err = appendJSONString(b, k); err != nil {
+				return false
+			}
+			b = append(b, `,"value":`...)
+			if b, err = appendAnyValue(b, v); err != nil {
+				return false
+			}
+			b = append(b, '}')
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, "]}"...)
+	default:
+		// an empty value has no field set.
+		b = append(b, '{')
+	}
+	return append(b, '}'), nil
+}
+
+func appendDouble(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(b, `"Infinity"`...)
+	case math.IsInf(f, -1):
+		return append(b, `"-Infinity"`...)
+	}
+	// encoded the same way as the json encoding, without an exponent for
+	// most values.
+	n, _ := json.Marshal(f)
+	return append(b, n...)
+}
+
+func appendJSONString(b []byte, s string) ([]byte, error) {
+	q, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, q...), nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func Test_otlpMapAsJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		args func(m pcommon.Map)
+		want string
+	}{
+		{
+			name: "empty",
+			args: func(m pcommon.Map) {},
+			want: `{}`,
+		},
+		{
+			name: "scalars",
+			args: func(m pcommon.Map) {
+				m.PutStr("http.method", "GET")
+				m.PutInt("http.status_code", 200)
+				m.PutDouble("ratio", 1)
+				m.PutBool("sampled", true)
+				m.PutEmptyBytes("id").FromRaw([]byte{0xca, 0xfe})
+				m.PutEmpty("nothing")
+			},
+			want: `{"http.method":{"stringValue":"GET"},"http.status_code":{"intValue":"200"},"ratio":{"doubleValue":1},` +
+				`"sampled":{"boolValue":true},"id":{"bytesValue":"yv4="},"nothing":{}}`,
+		},
+		{
+			name: "special doubles",
+			args: func(m pcommon.Map) {
+				m.PutDouble("nan", math.NaN())
+				m.PutDouble("inf", math.Inf(1))
+				m.PutDouble("-inf", math.Inf(-1))
+			},
+			want: `{"nan":{"doubleValue":"NaN"},"inf":{"doubleValue":"Infinity"},"-inf":{"doubleValue":"-Infinity"}}`,
+		},
+		{
+			name: "nested",
+			args: func(m pcommon.Map) {
+				m.PutEmptySlice("empty")
+				s := m.PutEmptySlice("slice")
+				s.AppendEmpty().SetStr("a")
+				s.AppendEmpty().SetInt(1)
+				kv := m.PutEmptyMap("kvlist")
+				kv.PutStr("b", "c")
+				kv.PutEmptyMap("d")
+			},
+			want: `{"empty":{"arrayValue":{"values":[]}},"slice":{"arrayValue":{"values":[{"stringValue":"a"},{"intValue":"1"}]}},` +
+				`"kvlist":{"kvlistValue":{"values":[{"key":"b","value":{"stringValue":"c"}},{"key":"d","value":{"kvlistValue":{"values":[]}}}]}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := pcommon.NewMap()
+			tt.args(m)
+			got, err := otlpMapAsJSON(m)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func Test_ExporterAttributeEncoding(t *testing.T) {
+	ctx := context.Background()
+
+	traces := ptrace.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{0x01})
+	span.SetSpanID(pcommon.SpanID{0x01})
+	span.Attributes().PutStr("http.method", "GET")
+	span.Attributes().PutInt("http.status_code", 200)
+	span.Attributes().PutDouble("ratio", 0.5)
+	span.Attributes().PutBool("sampled", true)
+
+	for _, encoding := range []string{AttributeEncodingJSON, AttributeEncodingOTLPJSON} {
+		t.Run(encoding, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Path = filepath.Join(t.TempDir(), "attributes.db")
+			cfg.AttributeEncoding = encoding
+
+			ex, err := newSqliteExporter(cfg)
+			require.NoError(t, err)
+			defer ex.Shutdown(ctx)
+			require.NoError(t, ex.ConsumeTraces(ctx, traces))
+
+			// the view reads the attributes the same way with either
+			// encoding.
+			rows, err := ex.db.Query("select key, type, value from span_attribute_values order by key;")
+			require.NoError(t, err)
+			defer rows.Close()
+			var got [][]any
+			for rows.Next() {
+				var key, typ string
+				var value any
+				require.NoError(t, rows.Scan(&key, &typ, &value))
+				got = append(got, []any{key, typ, value})
+			}
+			require.NoError(t, rows.Err())
+			assert.Equal(t, [][]any{
+				{"http.method", "string", "GET"},
+				{"http.status_code", "int", int64(200)},
+				{"ratio", "double", 0.5},
+				{"sampled", "bool", int64(1)},
+			}, got)
+		})
+	}
+}
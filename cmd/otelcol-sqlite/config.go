@@ -0,0 +1,61 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	defaultDB     = "./traces.db"
+	defaultListen = "localhost"
+)
+
+// defaultConfigTemplate is the config used when no --config flag is given. It
+// receives every signal over OTLP and writes it to a single database.
+// Internal metrics are turned off so that the collector doesn't need the
+// :8888 port.
+const defaultConfigTemplate = `receivers:
+  otlp:
+    protocols:
+      grpc:
+        endpoint: %[1]s
+      http:
+        endpoint: %[2]s
+
+processors:
+  batch:
+
+exporters:
+  sqlite:
+    path: %[3]s
+
+service:
+  telemetry:
+    metrics:
+      level: none
+  pipelines:
+    traces:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: [sqlite]
+    metrics:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: [sqlite]
+    logs:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: [sqlite]
+`
+
+// defaultConfig returns the default config writing to the database at path
+// and receiving OTLP on the default gRPC and HTTP ports of host.
+func defaultConfig(path, host string) string {
+	return fmt.Sprintf(defaultConfigTemplate,
+		strconv.Quote(net.JoinHostPort(host, "4317")),
+		strconv.Quote(net.JoinHostPort(host, "4318")),
+		strconv.Quote(path),
+	)
+}
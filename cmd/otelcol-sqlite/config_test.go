@@ -0,0 +1,64 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_defaultConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		host     string
+		wantGRPC string
+		wantHTTP string
+	}{
+		{
+			name:     "default",
+			path:     defaultDB,
+			host:     defaultListen,
+			wantGRPC: "localhost:4317",
+			wantHTTP: "localhost:4318",
+		},
+		{
+			name:     "quoted path",
+			path:     `/tmp/my "traces": #1.db`,
+			host:     "0.0.0.0",
+			wantGRPC: "0.0.0.0:4317",
+			wantHTTP: "0.0.0.0:4318",
+		},
+		{
+			name:     "ipv6",
+			path:     "traces.db",
+			host:     "::1",
+			wantGRPC: "[::1]:4317",
+			wantHTTP: "[::1]:4318",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg struct {
+				Receivers struct {
+					OTLP struct {
+						Protocols struct {
+							GRPC struct{ Endpoint string }
+							HTTP struct{ Endpoint string }
+						}
+					}
+				}
+				Exporters struct {
+					SQLite struct{ Path string }
+				}
+			}
+			require.NoError(t, yaml.Unmarshal([]byte(defaultConfig(tt.path, tt.host)), &cfg))
+
+			assert.Equal(t, tt.wantGRPC, cfg.Receivers.OTLP.Protocols.GRPC.Endpoint)
+			assert.Equal(t, tt.wantHTTP, cfg.Receivers.OTLP.Protocols.HTTP.Endpoint)
+			assert.Equal(t, tt.path, cfg.Exporters.SQLite.Path)
+		})
+	}
+}
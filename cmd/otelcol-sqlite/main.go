@@ -0,0 +1,122 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+
+// Command otelcol-sqlite is an OpenTelemetry Collector bundling the OTLP
+// receiver, the batch processor, the sqlite exporter and the sqlite_storage
+// extension.
+//
+// Without a --config flag, it receives OTLP on localhost and writes every
+// signal to ./traces.db:
+//
+//	otelcol-sqlite --db ./traces.db --listen localhost
+//
+// With one or more --config flags, it runs the given configs instead, like
+// any other collector distribution.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/confmap/converter/expandconverter"
+	"go.opentelemetry.io/collector/confmap/provider/envprovider"
+	"go.opentelemetry.io/collector/confmap/provider/fileprovider"
+	"go.opentelemetry.io/collector/confmap/provider/yamlprovider"
+	"go.opentelemetry.io/collector/otelcol"
+)
+
+// version is the version of the collector, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// stringsFlag is a flag that can be given several times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("otelcol-sqlite", flag.ContinueOnError)
+	var configs stringsFlag
+	fs.Var(&configs, "config", "Locations of the config files to use instead of the default config, e.g. file:config.yaml. Can be repeated.")
+	db := fs.String("db", defaultDB, "Path of the database file written by the default config.")
+	listen := fs.String("listen", defaultListen, "Host the default config receives OTLP on, on ports 4317 for gRPC and 4318 for HTTP.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	resolver, err := resolverSettings(fs, configs, *db, *listen)
+	if err != nil {
+		return err
+	}
+
+	col, err := otelcol.NewCollector(otelcol.CollectorSettings{
+		BuildInfo: component.BuildInfo{
+			Command:     "otelcol-sqlite",
+			Description: "OpenTelemetry Collector writing to a Sqlite database",
+			Version:     version,
+		},
+		Factories:              components,
+		ConfigProviderSettings: otelcol.ConfigProviderSettings{ResolverSettings: resolver},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collector: %w", err)
+	}
+	return col.Run(context.Background())
+}
+
+// resolverSettings returns the settings resolving the configs given on the
+// command line, or the default config if there are none. The --db and
+// --listen flags only apply to the default config.
+func resolverSettings(fs *flag.FlagSet, configs []string, db, listen string) (confmap.ResolverSettings, error) {
+	if len(configs) == 0 {
+		// the default config is passed as is, so a path containing a $ isn't
+		// expanded.
+		yaml := yamlprovider.New()
+		return confmap.ResolverSettings{
+			URIs:      []string{yaml.Scheme() + ":" + defaultConfig(db, listen)},
+			Providers: map[string]confmap.Provider{yaml.Scheme(): yaml},
+		}, nil
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "db" || f.Name == "listen" {
+			err = errors.Join(err, fmt.Errorf("--%s can't be used with --config", f.Name))
+		}
+	})
+	if err != nil {
+		return confmap.ResolverSettings{}, err
+	}
+
+	providers := make(map[string]confmap.Provider)
+	for _, p := range []confmap.Provider{fileprovider.New(), envprovider.New(), yamlprovider.New()} {
+		providers[p.Scheme()] = p
+	}
+	return confmap.ResolverSettings{
+		URIs:       configs,
+		Providers:  providers,
+		Converters: []confmap.Converter{expandconverter.New()},
+	}, nil
+}
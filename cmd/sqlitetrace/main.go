@@ -0,0 +1,133 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+
+// Command sqlitetrace inspects the traces of a database written by the sqlite
+// exporter. It reads the database through the query package, so it keeps
+// working as the schema is migrated, and never writes to it.
+//
+// Usage:
+//
+//	sqlitetrace <command> [flags] [args]
+//
+// The commands are:
+//
+//	traces   list the most recent traces
+//	show     print the span tree of a trace
+//	search   list the traces with spans matching filters
+//	stats    print the row counts, size and time range of the database
+//	schema   print the schema version of the database
+//
+// Every command reads ./traces.db unless given another file with -db.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"go.wperron.io/sqliteexporter/query"
+)
+
+// defaultDB is the database written by the default config of otelcol-sqlite.
+const defaultDB = "./traces.db"
+
+// command is a subcommand of sqlitetrace.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, w io.Writer, fs *flag.FlagSet, db *string, args []string) error
+}
+
+var commands = []command{
+	{name: "traces", summary: "list the most recent traces", run: runTraces},
+	{name: "show", args: "<trace-id>", summary: "print the span tree of a trace", run: runShow},
+	{name: "search", summary: "list the traces with spans matching filters", run: runSearch},
+	{name: "stats", summary: "print the row counts, size and time range of the database", run: runStats},
+	{name: "schema", summary: "print the schema version of the database", run: runSchema},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Stdout, os.Stderr, os.Args[1:])
+	stop()
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "sqlitetrace: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command of args, writing its output to w and the usage of
+// commands to stderr.
+func run(ctx context.Context, w, stderr io.Writer, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "usage: sqlitetrace %s [flags] %s\n\n%s.\n\nFlags:\n", c.name, c.args, c.summary)
+			fs.PrintDefaults()
+		}
+		db := fs.String("db", defaultDB, "Path of the database file.")
+		return c.run(ctx, w, fs, db, args[1:])
+	}
+
+	usage(stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, "usage: sqlitetrace <command> [flags] [args]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprint(w, "\nRun sqlitetrace <command> -h for the flags of a command.\n")
+}
+
+// parseFlags parses the flags of a command expecting nargs arguments.
+func parseFlags(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return fmt.Errorf("%s expects %d arguments, got %d", fs.Name(), nargs, fs.NArg())
+	}
+	return nil
+}
+
+// stringsFlag is a flag that can be given several times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// open opens the database at path, checking that it exists first for a
+// clearer error than the one of Sqlite.
+func open(ctx context.Context, path string) (*query.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return query.Open(ctx, path)
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package main
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	sqliteexporter "go.wperron.io/sqliteexporter"
+)
+
+const (
+	checkoutTraceID = "01010101010101010101010101010101"
+	healthTraceID   = "02020202020202020202020202020202"
+)
+
+// writeTestDB writes a checkout trace spanning three services, with a span
+// whose parent is missing, and a health check trace to a new database and
+// returns its path.
+func writeTestDB(t *testing.T) string {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+	ms := time.Millisecond
+	traces := ptrace.NewTraces()
+
+	addSpan := func(service string, traceID, spanID, parentID byte, name string, start, d time.Duration) ptrace.Span {
+		rs := traces.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID, traceID})
+		span.SetSpanID(pcommon.SpanID{traceID, 0, 0, 0, 0, 0, 0, spanID})
+		if parentID != 0 {
+			span.SetParentSpanID(pcommon.SpanID{traceID, 0, 0, 0, 0, 0, 0, parentID})
+		}
+		span.SetName(name)
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second + start)))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second + start + d)))
+		return span
+	}
+
+	checkout := addSpan("frontend", 0x01, 1, 0, "POST /checkout", 0, 500*ms)
+	checkout.Attributes().PutStr("http.method", "POST")
+	checkout.Attributes().PutInt("http.status_code", 500)
+	checkout.Attributes().PutStr("user.id", "42")
+	checkout.Status().SetCode(ptrace.StatusCodeError)
+	addSpan("cart", 0x01, 2, 1, "GetCart", 10*ms, 40*ms).Attributes().PutStr("rpc.method", "GetCart")
+	charge := addSpan("payments", 0x01, 3, 1, "Charge", 100*ms, 300*ms)
+	charge.Attributes().PutStr("rpc.method", "Charge")
+	charge.Status().SetCode(ptrace.StatusCodeError)
+	charge.Status().SetMessage("card declined")
+	addSpan("payments", 0x01, 4, 3, "SELECT cards", 150*ms, 20*ms).Attributes().PutStr("db.system", "sqlite")
+	addSpan("payments", 0x01, 6, 5, "Refund", 600*ms, 10*ms)
+	addSpan("frontend", 0x02, 1, 0, "GET /health", 900*ms, ms).Status().SetCode(ptrace.StatusCodeOk)
+
+	factory := sqliteexporter.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*sqliteexporter.Config)
+	cfg.Path = filepath.Join(t.TempDir(), "traces.db")
+	cfg.QueueSettings.Enabled = false
+
+	exp, err := factory.CreateTracesExporter(ctx, exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, exp.ConsumeTraces(ctx, traces))
+	require.NoError(t, exp.Shutdown(ctx))
+	return cfg.Path
+}
+
+// runCommand runs sqlitetrace with the args and returns its output.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	var out, stderr strings.Builder
+	err := run(context.Background(), &out, &stderr, args)
+	return out.String(), err
+}
+
+func Test_show(t *testing.T) {
+	path := writeTestDB(t)
+
+	out, err := runCommand(t, "show", "-db", path, checkoutTraceID)
+	require.NoError(t, err)
+	assert.Equal(t, `frontend: POST /checkout 500ms at +0s ERROR http.method=POST http.status_code=500
+  cart: GetCart 40ms at +10ms rpc.method=GetCart
+  payments: Charge 300ms at +100ms ERROR "card declined" rpc.method=Charge
+    payments: SELECT cards 20ms at +150ms db.system=sqlite
+payments: Refund (parent 0100000000000005 missing) 10ms at +600ms
+`, out)
+
+	out, err = runCommand(t, "show", "-db", path, "-attrs", "user.id", checkoutTraceID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "frontend: POST /checkout 500ms at +0s ERROR user.id=42\n")
+
+	out, err = runCommand(t, "show", "-db", path, "-all-attrs", checkoutTraceID)
+	require.NoError(t, err)
+	assert.Contains(t, out, "frontend: POST /checkout 500ms at +0s ERROR http.method=POST http.status_code=500 user.id=42\n")
+
+	_, err = runCommand(t, "show", "-db", path, "ff")
+	assert.EqualError(t, err, `invalid trace id "ff", must be 32 hex digits`)
+	_, err = runCommand(t, "show", "-db", path, strings.Repeat("f", 32))
+	assert.EqualError(t, err, "trace not found")
+}
+
+func Test_traces(t *testing.T) {
+	path := writeTestDB(t)
+
+	out, err := runCommand(t, "traces", "-db", path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^TRACE ID\s+START\s+DURATION\s+SPANS\s+ERRORS\s+ROOT$`, lines[0])
+	// the most recent trace first.
+	assert.Regexp(t, `^`+healthTraceID+`\s+\S+ \S+\s+1ms\s+1\s+0\s+frontend: GET /health$`, lines[1])
+	assert.Regexp(t, `^`+checkoutTraceID+`\s+\S+ \S+\s+610ms\s+5\s+2\s+frontend: POST /checkout$`, lines[2])
+
+	out, err = runCommand(t, "traces", "-db", path, "-limit", "1")
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+}
+
+func Test_search(t *testing.T) {
+	path := writeTestDB(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "service", args: []string{"-service", "payments"}, want: []string{checkoutTraceID}},
+		{name: "name", args: []string{"-name", "GET /health"}, want: []string{healthTraceID}},
+		{name: "int attribute", args: []string{"-attr", "http.status_code=500"}, want: []string{checkoutTraceID}},
+		{name: "string attribute", args: []string{"-attr", "http.method=POST"}, want: []string{checkoutTraceID}},
+		{name: "quoted attribute", args: []string{"-attr", `user.id="42"`}, want: []string{checkoutTraceID}},
+		{name: "attribute type mismatch", args: []string{"-attr", "user.id=42"}},
+		{name: "min duration", args: []string{"-min-duration", "400ms"}, want: []string{checkoutTraceID}},
+		{name: "max duration", args: []string{"-max-duration", "5ms"}, want: []string{healthTraceID}},
+		{name: "status", args: []string{"-status", "ok"}, want: []string{healthTraceID}},
+		{name: "statuses", args: []string{"-status", "ok", "-status", "ERROR"}, want: []string{healthTraceID, checkoutTraceID}},
+		{name: "combined", args: []string{"-service", "cart", "-status", "error"}},
+		{name: "since", args: []string{"-since", "1h"}, want: []string{healthTraceID, checkoutTraceID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runCommand(t, append([]string{"search", "-db", path}, tt.args...)...)
+			require.NoError(t, err)
+
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n")[1:] {
+				got = append(got, strings.Fields(line)[0])
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := runCommand(t, "search", "-db", path, "-status", "failed")
+	assert.EqualError(t, err, `invalid status "failed", must be one of unset, ok or error`)
+	_, err = runCommand(t, "search", "-db", path, "-attr", "http.method")
+	assert.EqualError(t, err, `invalid attribute "http.method", must be key=value`)
+}
+
+func Test_parseAttribute(t *testing.T) {
+	tests := []struct {
+		in   string
+		key  string
+		want any
+	}{
+		{in: "k=v", key: "k", want: "v"},
+		{in: "k=", key: "k", want: ""},
+		{in: "k=a=b", key: "k", want: "a=b"},
+		{in: "k=200", key: "k", want: int64(200)},
+		{in: "k=-1.5", key: "k", want: -1.5},
+		{in: "k=1e3", key: "k", want: 1000.0},
+		{in: "k=true", key: "k", want: true},
+		{in: `k="200"`, key: "k", want: "200"},
+		{in: "k=NaN", key: "k", want: "NaN"},
+		{in: "k=null", key: "k", want: "null"},
+		{in: "k=[1]", key: "k", want: "[1]"},
+	}
+	for _, tt := range tests {
+		k, v, err := parseAttribute(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.key, k, tt.in)
+		assert.Equal(t, tt.want, v, tt.in)
+	}
+}
+
+func Test_stats(t *testing.T) {
+	path := writeTestDB(t)
+
+	out, err := runCommand(t, "stats", "-db", path)
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^size: \d+\.\d KiB$`, out)
+	assert.Regexp(t, `(?m)^spans: \S+ \S+ to \S+ \S+$`, out)
+	assert.Regexp(t, `(?m)^\s*spans\s+6\s*$`, out)
+	assert.Regexp(t, `(?m)^\s*traces\s+2\s*$`, out)
+
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KiB", formatSize(1536))
+	assert.Equal(t, "2.0 MiB", formatSize(2<<20))
+}
+
+func Test_schema(t *testing.T) {
+	path := writeTestDB(t)
+
+	out, err := runCommand(t, "schema", "-db", path)
+	require.NoError(t, err)
+	assert.Regexp(t, `^version: \d{14}\n$`, out)
+}
+
+func Test_run(t *testing.T) {
+	_, err := runCommand(t)
+	assert.ErrorIs(t, err, flag.ErrHelp)
+	_, err = runCommand(t, "trace")
+	assert.EqualError(t, err, `unknown command "trace"`)
+	_, err = runCommand(t, "stats", "-db", filepath.Join(t.TempDir(), "missing.db"))
+	assert.ErrorContains(t, err, "no such file or directory")
+	_, err = runCommand(t, "schema", "extra")
+	assert.EqualError(t, err, "schema expects 0 arguments, got 1")
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// keyAttributes are the span attributes printed by show by default, the
+// semantic conventions describing what a span did.
+var keyAttributes = []string{
+	"http.request.method",
+	"http.method",
+	"http.route",
+	"url.full",
+	"http.url",
+	"http.response.status_code",
+	"http.status_code",
+	"rpc.system",
+	"rpc.service",
+	"rpc.method",
+	"db.system",
+	"db.operation",
+	"messaging.system",
+	"messaging.destination.name",
+	"peer.service",
+	"error.type",
+}
+
+// treeSpan is a span of the tree printed by show.
+type treeSpan struct {
+	span     ptrace.Span
+	service  string
+	children []*treeSpan
+}
+
+func runShow(ctx context.Context, w io.Writer, fs *flag.FlagSet, path *string, args []string) error {
+	attrs := fs.String("attrs", strings.Join(keyAttributes, ","), "Comma-separated keys of the span attributes to print.")
+	allAttrs := fs.Bool("all-attrs", false, "Print every attribute of the spans instead of -attrs.")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	traceID, err := parseTraceID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	db, err := open(ctx, *path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	traces, err := db.GetTrace(ctx, traceID)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	if !*allAttrs {
+		keys = strings.Split(*attrs, ",")
+	}
+	roots, start := spanTree(traces)
+	for _, root := range roots {
+		writeSpan(w, root, 0, start, keys)
+	}
+	return nil
+}
+
+// parseTraceID parses a trace ID written as 32 hex digits.
+func parseTraceID(s string) (pcommon.TraceID, error) {
+	var id pcommon.TraceID
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("invalid trace id %q, must be 32 hex digits", s)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// spanTree returns the root spans of the trace, along with the start time of
+// its earliest span. The spans whose parent isn't stored are printed as roots,
+// after the actual root. Siblings are sorted by start time.
+func spanTree(traces ptrace.Traces) ([]*treeSpan, pcommon.Timestamp) {
+	spans := make(map[pcommon.SpanID]*treeSpan)
+	var start pcommon.Timestamp
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		rs := traces.ResourceSpans().At(i)
+		service := ""
+		if v, ok := rs.Resource().Attributes().Get("service.name"); ok {
+			service = v.AsString()
+		}
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				spans[span.SpanID()] = &treeSpan{span: span, service: service}
+				if start == 0 || span.StartTimestamp() < start {
+					start = span.StartTimestamp()
+				}
+			}
+		}
+	}
+
+	var roots []*treeSpan
+	for _, s := range spans {
+		parent, ok := spans[s.span.ParentSpanID()]
+		if s.span.ParentSpanID().IsEmpty() || !ok {
+			roots = append(roots, s)
+			continue
+		}
+		parent.children = append(parent.children, s)
+	}
+
+	sortSpans(roots)
+	for _, s := range spans {
+		sortSpans(s.children)
+	}
+	return roots, start
+}
+
+// sortSpans sorts the spans by start time, the spans with a parent after the
+// ones without for roots.
+func sortSpans(spans []*treeSpan) {
+	sort.Slice(spans, func(i, j int) bool {
+		a, b := spans[i].span, spans[j].span
+		if ar, br := a.ParentSpanID().IsEmpty(), b.ParentSpanID().IsEmpty(); ar != br {
+			return ar
+		}
+		if a.StartTimestamp() != b.StartTimestamp() {
+			return a.StartTimestamp() < b.StartTimestamp()
+		}
+		ai, bi := a.SpanID(), b.SpanID()
+		return bytes.Compare(ai[:], bi[:]) < 0
+	})
+}
+
+// writeSpan writes a line for the span and its descendants, indented by their
+// depth: the service and name of the span, its duration, its offset from the
+// start of the trace, its status and attributes.
+func writeSpan(w io.Writer, s *treeSpan, depth int, start pcommon.Timestamp, keys []string) {
+	span := s.span
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", depth))
+	if s.service != "" {
+		b.WriteString(s.service + ": ")
+	}
+	b.WriteString(span.Name())
+	if !span.ParentSpanID().IsEmpty() && depth == 0 {
+		fmt.Fprintf(&b, " (parent %s missing)", span.ParentSpanID())
+	}
+	duration := time.Duration(span.EndTimestamp() - span.StartTimestamp())
+	offset := time.Duration(span.StartTimestamp() - start)
+	fmt.Fprintf(&b, " %s at +%s", duration, offset)
+
+	if code := span.Status().Code(); code != ptrace.StatusCodeUnset {
+		b.WriteString(" " + strings.ToUpper(code.String()))
+		if msg := span.Status().Message(); msg != "" {
+			b.WriteString(" " + strconv.Quote(msg))
+		}
+	}
+
+	for _, kv := range attributes(span.Attributes(), keys) {
+		b.WriteString(" " + kv)
+	}
+
+	fmt.Fprintln(w, b.String())
+	for _, c := range s.children {
+		writeSpan(w, c, depth+1, start, keys)
+	}
+}
+
+// attributes returns the attributes of the keys as key=value, in the order of
+// the keys, or every attribute sorted by key when keys is nil. Strings are
+// quoted when they'd be ambiguous otherwise.
+func attributes(m pcommon.Map, keys []string) []string {
+	if keys == nil {
+		m.Range(func(k string, _ pcommon.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+		sort.Strings(keys)
+	}
+
+	var kvs []string
+	for _, k := range keys {
+		v, ok := m.Get(k)
+		if !ok {
+			continue
+		}
+		s := v.AsString()
+		if v.Type() == pcommon.ValueTypeStr && (s == "" || strings.ContainsAny(s, " \t\n\"=")) {
+			s = strconv.Quote(s)
+		}
+		kvs = append(kvs, k+"="+s)
+	}
+	return kvs
+}
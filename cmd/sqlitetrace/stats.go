@@ -0,0 +1,85 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"go.wperron.io/sqliteexporter/query"
+)
+
+func runStats(ctx context.Context, w io.Writer, fs *flag.FlagSet, path *string, args []string) error {
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	db, err := open(ctx, *path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "size: %s\n", formatSize(stats.Size))
+	if stats.Start.IsZero() {
+		fmt.Fprintln(w, "spans: none")
+	} else {
+		fmt.Fprintf(w, "spans: %s to %s\n", stats.Start.Local().Format(timeFormat), stats.End.Local().Format(timeFormat))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TABLE\tROWS\t")
+	for _, t := range stats.Tables {
+		fmt.Fprintf(tw, "%s\t%d\t\n", t.Name, t.Rows)
+	}
+	return tw.Flush()
+}
+
+// formatSize formats a size in bytes with a binary unit.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func runSchema(ctx context.Context, w io.Writer, fs *flag.FlagSet, path *string, args []string) error {
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	db, err := open(ctx, *path)
+	var versionErr *query.SchemaVersionError
+	if errors.As(err, &versionErr) {
+		// the other commands can't read the database, but its version is
+		// still worth reporting.
+		fmt.Fprintf(w, "version: %d (older than %d, run the exporter to migrate it)\n", versionErr.Version, versionErr.MinVersion)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "version: %d\n", version)
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"go.wperron.io/sqliteexporter/query"
+)
+
+// timeFormat is the format of the times printed by the commands.
+const timeFormat = "2006-01-02 15:04:05.000"
+
+func runTraces(ctx context.Context, w io.Writer, fs *flag.FlagSet, path *string, args []string) error {
+	limit := fs.Int("limit", 20, "Maximum number of traces listed.")
+	since := fs.Duration("since", 0, "Only list the traces with spans started in this last duration, e.g. 15m.")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	q := query.SearchQuery{Limit: *limit}
+	if *since > 0 {
+		q.Start = time.Now().Add(-*since)
+	}
+	return searchTraces(ctx, w, *path, q)
+}
+
+func runSearch(ctx context.Context, w io.Writer, fs *flag.FlagSet, path *string, args []string) error {
+	var q query.SearchQuery
+	var attrs, statuses stringsFlag
+	fs.StringVar(&q.ServiceName, "service", "", "Service name of the spans.")
+	fs.StringVar(&q.SpanName, "name", "", "Name of the spans.")
+	fs.Var(&attrs, "attr", "Attribute of the spans or of their resource, as key=value. Values that are JSON numbers, booleans or strings match attributes of that type, others match strings. Can be repeated.")
+	fs.DurationVar(&q.MinDuration, "min-duration", 0, "Minimum duration of the spans, e.g. 250ms.")
+	fs.DurationVar(&q.MaxDuration, "max-duration", 0, "Maximum duration of the spans.")
+	fs.Var(&statuses, "status", "Status of the spans, one of unset, ok or error. Can be repeated.")
+	since := fs.Duration("since", 0, "Only search the spans started in this last duration, e.g. 15m.")
+	fs.IntVar(&q.Limit, "limit", 20, "Maximum number of traces listed.")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	for _, a := range attrs {
+		k, v, err := parseAttribute(a)
+		if err != nil {
+			return err
+		}
+		if q.Attributes == nil {
+			q.Attributes = make(map[string]any)
+		}
+		q.Attributes[k] = v
+	}
+	for _, s := range statuses {
+		code, err := parseStatus(s)
+		if err != nil {
+			return err
+		}
+		q.StatusCodes = append(q.StatusCodes, code)
+	}
+	if *since > 0 {
+		q.Start = time.Now().Add(-*since)
+	}
+	return searchTraces(ctx, w, *path, q)
+}
+
+// searchTraces prints the traces of the database matching q, one per line.
+func searchTraces(ctx context.Context, w io.Writer, path string, q query.SearchQuery) error {
+	db, err := open(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	traces, err := db.SearchTraces(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRACE ID\tSTART\tDURATION\tSPANS\tERRORS\tROOT")
+	for _, t := range traces {
+		root := "(missing)"
+		if t.RootSpanName != "" || t.RootServiceName != "" {
+			root = t.RootServiceName + ": " + t.RootSpanName
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\n", t.TraceID, t.Start.Local().Format(timeFormat), t.Duration, t.SpanCount, t.ErrorCount, root)
+	}
+	return tw.Flush()
+}
+
+// parseAttribute parses an attribute filter of the form key=value. Values
+// that are valid JSON numbers, booleans or strings are searched with that
+// type, any other value is searched as a string.
+func parseAttribute(s string) (string, any, error) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return "", nil, fmt.Errorf("invalid attribute %q, must be key=value", s)
+	}
+	if !json.Valid([]byte(v)) {
+		return k, v, nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return k, v, nil
+	}
+	switch raw := raw.(type) {
+	case json.Number:
+		if i, err := raw.Int64(); err == nil {
+			return k, i, nil
+		}
+		f, err := raw.Float64()
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid attribute %q: %w", s, err)
+		}
+		return k, f, nil
+	case bool, string:
+		return k, raw, nil
+	default:
+		return k, v, nil
+	}
+}
+
+// parseStatus parses the name of a status code.
+func parseStatus(s string) (ptrace.StatusCode, error) {
+	switch strings.ToLower(s) {
+	case "unset":
+		return ptrace.StatusCodeUnset, nil
+	case "ok":
+		return ptrace.StatusCodeOk, nil
+	case "error":
+		return ptrace.StatusCodeError, nil
+	default:
+		return 0, fmt.Errorf("invalid status %q, must be one of unset, ok or error", s)
+	}
+}
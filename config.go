@@ -3,31 +3,562 @@ package sqliteexporter
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
 	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 )
 
 var _ component.Config = (*Config)(nil)
 
 type Config struct {
+	exporterhelper.TimeoutSettings `mapstructure:",squash"`
+	exporterhelper.QueueSettings   `mapstructure:"sending_queue"`
+	configretry.BackOffConfig      `mapstructure:"retry_on_failure"`
+
 	// Path of the sqlite3 database file. Path is relative to current directory.
 	// If file does not exist, it will be created by the exporter.
 	Path string `mapstructure:"path"`
 
-	// TODO(wperron) add options for WAL/journal mode, etc.
+	// Pragmas are applied to every connection opened on the database.
+	Pragmas PragmaConfig `mapstructure:"pragmas"`
+
+	// RowsPerStatement is the maximum number of rows inserted by a single
+	// multi-row INSERT statement. Defaults to 1, a single row per statement.
+	RowsPerStatement int `mapstructure:"rows_per_statement"`
+
+	// OnConflict is how spans that already exist in the database are handled,
+	// one of ignore, replace or fail. Defaults to ignore, so that retrying a
+	// batch that was already written is a no-op.
+	OnConflict string `mapstructure:"on_conflict"`
+
+	// HoistedAttributes are resource or span attributes copied to their own
+	// indexed column of the spans table, in addition to the attributes JSON.
+	HoistedAttributes []HoistedAttribute `mapstructure:"hoisted_attributes"`
+
+	// AttributeIndex controls the span attributes written to the
+	// span_attributes table, in addition to the attributes JSON.
+	AttributeIndex AttributeIndexConfig `mapstructure:"attribute_index"`
+
+	// Rollups controls the span_rollups table of request, error and duration
+	// aggregates of the spans.
+	Rollups RollupsConfig `mapstructure:"rollups"`
+
+	// ServiceGraph controls the service_edges table of the calls between
+	// services.
+	ServiceGraph ServiceGraphConfig `mapstructure:"service_graph"`
+
+	// Retention controls how old data is pruned from the database.
+	Retention RetentionConfig `mapstructure:"retention"`
+
+	// Schema is how resources and instrumentation scopes are stored, either
+	// denormalized, inlined in every span, or normalized, in their own
+	// deduplicated tables. Defaults to denormalized.
+	Schema string `mapstructure:"schema"`
+
+	// JSONFormat is how the attributes of spans, events and links are stored,
+	// either text or jsonb. Existing rows are converted on startup when it
+	// changes. Defaults to text.
+	JSONFormat string `mapstructure:"json_format"`
+
+	// TimestampPrecision is the precision of the timestamps of spans and
+	// events, either us or ns. Microsecond timestamps are always written,
+	// with ns the nanosecond ones are written to their own columns as well.
+	// Defaults to us.
+	TimestampPrecision string `mapstructure:"timestamp_precision"`
+
+	// AttributeEncoding is how attribute values are encoded in the attributes
+	// JSON of every signal, either json, plain JSON values, or otlp_json, the
+	// OTLP AnyValue JSON encoding which keeps the exact type of every value.
+	// Defaults to json.
+	AttributeEncoding string `mapstructure:"attribute_encoding"`
+
+	// MaxBatchDelay is how long the writer waits for more batches before
+	// committing the ones it already has in a single transaction. Defaults to
+	// 0, only the batches already waiting to be written are grouped.
+	MaxBatchDelay time.Duration `mapstructure:"max_batch_delay"`
+
+	// MaxBatchRows is the number of spans, log records and metric data points
+	// after which the writer commits without waiting for more batches. 0
+	// removes the limit. Defaults to 10000.
+	MaxBatchRows int `mapstructure:"max_batch_rows"`
+}
+
+// RetentionConfig controls the background pruning of the database. Pruning is
+// disabled when both MaxAge and MaxSizeBytes are zero.
+type RetentionConfig struct {
+	// MaxAge is how long spans, logs and metric data points are kept. Spans
+	// are pruned based on their start time, along with their events and links.
+	MaxAge time.Duration `mapstructure:"max_age"`
+
+	// MaxSizeBytes is the maximum size of the data in the database. When it is
+	// exceeded, the oldest spans are deleted until the database fits.
+	MaxSizeBytes int64 `mapstructure:"max_size_bytes"`
+
+	// CheckInterval is how often the database is pruned.
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+
+	// IncrementalVacuum runs an incremental vacuum after pruning to return the
+	// freed pages to the file system. It only has an effect when the database
+	// uses the incremental auto_vacuum mode.
+	IncrementalVacuum bool `mapstructure:"incremental_vacuum"`
+}
+
+func (r *RetentionConfig) enabled() bool {
+	return r.MaxAge > 0 || r.MaxSizeBytes > 0
+}
+
+func (r *RetentionConfig) validate() error {
+	if r.MaxAge < 0 {
+		return errors.New("max_age must be non-negative")
+	}
+
+	if r.MaxSizeBytes < 0 {
+		return errors.New("max_size_bytes must be non-negative")
+	}
+
+	if r.enabled() && r.CheckInterval <= 0 {
+		return errors.New("check_interval must be positive")
+	}
+
+	return nil
+}
+
+// AttributeIndexConfig controls the span_attributes table, which has a row
+// for every indexed span attribute so spans can be searched by attribute
+// value without scanning the attributes JSON of every span.
+type AttributeIndexConfig struct {
+	// Enabled writes the span attributes to the span_attributes table.
+	Enabled bool `mapstructure:"enabled"`
+
+	// Keys are the keys of the indexed attributes. When empty, every
+	// attribute is indexed.
+	Keys []string `mapstructure:"keys"`
+
+	// MaxAttributesPerSpan is the maximum number of attributes indexed for a
+	// single span, the first ones in the order of the span attributes. 0
+	// removes the limit.
+	MaxAttributesPerSpan int `mapstructure:"max_attributes_per_span"`
+}
+
+func (a *AttributeIndexConfig) validate() error {
+	for i, key := range a.Keys {
+		if key == "" {
+			return fmt.Errorf("key %d must be non-empty", i)
+		}
+	}
+
+	if a.MaxAttributesPerSpan < 0 {
+		return errors.New("max_attributes_per_span must be non-negative")
+	}
+
+	return nil
+}
+
+// RollupsConfig controls the span_rollups table, which has the number of
+// requests and errors and the durations of the spans of every service, span
+// name, kind and status code by time bucket, so rates, errors and latencies
+// can be read over long periods without scanning the spans, and after they
+// are pruned.
+type RollupsConfig struct {
+	// Enabled writes the rollups of the spans to the span_rollups table.
+	Enabled bool `mapstructure:"enabled"`
+
+	// BucketWidth is the width of the time buckets the spans are rolled up
+	// in, by start time. Defaults to 1m.
+	BucketWidth time.Duration `mapstructure:"bucket_width"`
 
-	// TODO(wperron) add option of "hoisted fields" like service name and duration
+	// Histogram is how span durations are bucketed.
+	Histogram RollupHistogramConfig `mapstructure:"histogram"`
 }
 
+// RollupHistogramConfig describes the duration histogram of the rollups.
+type RollupHistogramConfig struct {
+	// Type is either explicit, buckets with fixed boundaries, or exponential,
+	// buckets whose boundaries grow exponentially with a fixed scale.
+	// Defaults to explicit.
+	Type string `mapstructure:"type"`
+
+	// Boundaries are the upper bounds of the explicit buckets, inclusive.
+	// Defaults to 5ms, 10ms, 25ms, 50ms, 100ms, 250ms, 500ms, 1s, 2.5s, 5s
+	// and 10s.
+	Boundaries []time.Duration `mapstructure:"boundaries"`
+
+	// Scale is the scale of the exponential buckets, as defined by the
+	// OpenTelemetry exponential histograms: the boundaries are powers of
+	// 2^(2^-scale) nanoseconds. Defaults to 3.
+	Scale int32 `mapstructure:"scale"`
+}
+
+func (r *RollupsConfig) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+
+	if r.BucketWidth < time.Second {
+		return errors.New("bucket_width must be at least 1s")
+	}
+
+	switch r.Histogram.Type {
+	case RollupHistogramExplicit:
+		if len(r.Histogram.Boundaries) == 0 {
+			return errors.New("histogram boundaries must be non-empty")
+		}
+		for i, b := range r.Histogram.Boundaries {
+			if b <= 0 || (i > 0 && b <= r.Histogram.Boundaries[i-1]) {
+				return errors.New("histogram boundaries must be positive and increasing")
+			}
+		}
+	case RollupHistogramExponential:
+		if r.Histogram.Scale < minRollupScale || r.Histogram.Scale > maxRollupScale {
+			return fmt.Errorf("histogram scale must be between %d and %d, got %d", minRollupScale, maxRollupScale, r.Histogram.Scale)
+		}
+	default:
+		return fmt.Errorf("histogram type must be one of %s or %s, got %q", RollupHistogramExplicit, RollupHistogramExponential, r.Histogram.Type)
+	}
+
+	return nil
+}
+
+// ServiceGraphConfig controls the service_edges table, which has the number of
+// calls between every pair of services by time bucket, from the spans whose
+// parent span belongs to another service.
+type ServiceGraphConfig struct {
+	// Enabled writes the calls between services to the service_edges table.
+	Enabled bool `mapstructure:"enabled"`
+
+	// BucketWidth is the width of the time buckets calls are counted in, by
+	// start time of the child span. Defaults to 1m.
+	BucketWidth time.Duration `mapstructure:"bucket_width"`
+}
+
+func (g *ServiceGraphConfig) validate() error {
+	if g.Enabled && g.BucketWidth < time.Second {
+		return errors.New("bucket_width must be at least 1s")
+	}
+	return nil
+}
+
+// HoistedAttribute describes an attribute stored in its own column.
+type HoistedAttribute struct {
+	// Source is where the attribute is read from, either resource or span.
+	Source string `mapstructure:"source"`
+
+	// Key is the attribute key, e.g. deployment.environment.
+	Key string `mapstructure:"key"`
+
+	// Column is the name of the column added to the spans table. Defaults to
+	// the key with every character that isn't allowed in an unquoted column
+	// name replaced by an underscore.
+	Column string `mapstructure:"column"`
+
+	// Type is the type of the column, one of TEXT, INTEGER or REAL. Defaults
+	// to TEXT. Values that can't be converted to the column type are stored
+	// as NULL.
+	Type string `mapstructure:"type"`
+}
+
+const (
+	HoistedSourceResource = "resource"
+	HoistedSourceSpan     = "span"
+)
+
+var (
+	columnNameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	invalidNameRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	columnTypes   = []string{"TEXT", "INTEGER", "REAL"}
+)
+
+// column returns the name of the column the attribute is stored in.
+func (h HoistedAttribute) column() string {
+	if h.Column != "" {
+		return h.Column
+	}
+	return invalidNameRe.ReplaceAllString(h.Key, "_")
+}
+
+// columnType returns the type of the column the attribute is stored in.
+func (h HoistedAttribute) columnType() string {
+	if h.Type == "" {
+		return "TEXT"
+	}
+	return strings.ToUpper(h.Type)
+}
+
+func (h HoistedAttribute) validate() error {
+	if h.Source != HoistedSourceResource && h.Source != HoistedSourceSpan {
+		return fmt.Errorf("source must be one of %s or %s, got %q", HoistedSourceResource, HoistedSourceSpan, h.Source)
+	}
+
+	if h.Key == "" {
+		return errors.New("key must be non-empty")
+	}
+
+	col := h.column()
+	if !columnNameRe.MatchString(col) {
+		return fmt.Errorf("invalid column name %q", col)
+	}
+
+	for _, builtins := range [][]string{spansSpec.columns, spanTreeColumns, rollupColumns, serviceGraphColumns} {
+		for _, builtin := range builtins {
+			if strings.EqualFold(col, builtin) {
+				return fmt.Errorf("column %q already exists in the spans table", col)
+			}
+		}
+	}
+
+	if !oneOf(h.columnType(), columnTypes) {
+		return fmt.Errorf("type must be one of %s, got %q", strings.Join(columnTypes, ", "), h.Type)
+	}
+
+	return nil
+}
+
+// PragmaConfig holds the SQLite pragmas set on every connection. Zero values
+// leave the SQLite default in place.
+//
+// See https://www.sqlite.org/pragma.html for details on each pragma.
+type PragmaConfig struct {
+	// JournalMode is one of delete, truncate, persist, memory, wal or off.
+	// Defaults to wal, which lets other processes read the database while the
+	// exporter is writing to it.
+	JournalMode string `mapstructure:"journal_mode"`
+
+	// Synchronous is one of off, normal, full or extra.
+	Synchronous string `mapstructure:"synchronous"`
+
+	// BusyTimeout is how long a connection waits on a locked database before
+	// returning SQLITE_BUSY.
+	BusyTimeout time.Duration `mapstructure:"busy_timeout"`
+
+	// CacheSize is the suggested maximum number of database pages held in
+	// memory, or the amount of memory in KiB when negative.
+	CacheSize int `mapstructure:"cache_size"`
+
+	// MmapSize is the maximum number of bytes of the database file that can be
+	// accessed using memory-mapped I/O.
+	MmapSize int64 `mapstructure:"mmap_size"`
+
+	// TempStore is one of default, file or memory.
+	TempStore string `mapstructure:"temp_store"`
+
+	// AutoVacuum is one of none, full or incremental. It can only be changed
+	// before the first table is created, so it only has an effect on new
+	// databases.
+	AutoVacuum string `mapstructure:"auto_vacuum"`
+
+	// ForeignKeys enables the enforcement of foreign key constraints.
+	ForeignKeys bool `mapstructure:"foreign_keys"`
+}
+
+const (
+	SchemaDenormalized = "denormalized"
+	SchemaNormalized   = "normalized"
+)
+
+const (
+	JSONFormatText  = "text"
+	JSONFormatJSONB = "jsonb"
+)
+
+const (
+	TimestampPrecisionMicro = "us"
+	TimestampPrecisionNano  = "ns"
+)
+
+const (
+	AttributeEncodingJSON     = "json"
+	AttributeEncodingOTLPJSON = "otlp_json"
+)
+
+const (
+	RollupHistogramExplicit    = "explicit"
+	RollupHistogramExponential = "exponential"
+)
+
+// minRollupScale and maxRollupScale are the scales supported by the
+// OpenTelemetry exponential histograms.
+const (
+	minRollupScale = -10
+	maxRollupScale = 20
+)
+
+const (
+	OnConflictIgnore  = "ignore"
+	OnConflictReplace = "replace"
+	OnConflictFail    = "fail"
+)
+
+// maxRowsPerStatement keeps multi-row statements well under the limit of
+// host parameters per statement.
+const maxRowsPerStatement = 1000
+
+var (
+	journalModes = []string{"delete", "truncate", "persist", "memory", "wal", "off"}
+	synchronous  = []string{"off", "normal", "full", "extra"}
+	tempStores   = []string{"default", "file", "memory"}
+	autoVacuums  = []string{"none", "full", "incremental"}
+)
+
 func (cfg *Config) Validate() error {
 	if cfg.Path == "" {
 		return errors.New("path must be non-empty")
 	}
 
+	if cfg.RowsPerStatement < 0 || cfg.RowsPerStatement > maxRowsPerStatement {
+		return fmt.Errorf("rows_per_statement must be between 0 and %d", maxRowsPerStatement)
+	}
+
+	switch cfg.OnConflict {
+	case "", OnConflictIgnore, OnConflictReplace, OnConflictFail:
+	default:
+		return fmt.Errorf("on_conflict must be one of %s, %s or %s, got %q", OnConflictIgnore, OnConflictReplace, OnConflictFail, cfg.OnConflict)
+	}
+
+	switch cfg.Schema {
+	case "", SchemaDenormalized, SchemaNormalized:
+	default:
+		return fmt.Errorf("schema must be one of %s or %s, got %q", SchemaDenormalized, SchemaNormalized, cfg.Schema)
+	}
+
+	switch cfg.JSONFormat {
+	case "", JSONFormatText, JSONFormatJSONB:
+	default:
+		return fmt.Errorf("json_format must be one of %s or %s, got %q", JSONFormatText, JSONFormatJSONB, cfg.JSONFormat)
+	}
+
+	switch cfg.TimestampPrecision {
+	case "", TimestampPrecisionMicro, TimestampPrecisionNano:
+	default:
+		return fmt.Errorf("timestamp_precision must be one of %s or %s, got %q", TimestampPrecisionMicro, TimestampPrecisionNano, cfg.TimestampPrecision)
+	}
+
+	switch cfg.AttributeEncoding {
+	case "", AttributeEncodingJSON, AttributeEncodingOTLPJSON:
+	default:
+		return fmt.Errorf("attribute_encoding must be one of %s or %s, got %q", AttributeEncodingJSON, AttributeEncodingOTLPJSON, cfg.AttributeEncoding)
+	}
+
+	if cfg.MaxBatchDelay < 0 {
+		return errors.New("max_batch_delay must be non-negative")
+	}
+
+	if cfg.MaxBatchRows < 0 {
+		return errors.New("max_batch_rows must be non-negative")
+	}
+
+	if err := cfg.Pragmas.validate(); err != nil {
+		return fmt.Errorf("invalid pragmas: %w", err)
+	}
+
+	columns := make(map[string]struct{}, len(cfg.HoistedAttributes))
+	for i, h := range cfg.HoistedAttributes {
+		if err := h.validate(); err != nil {
+			return fmt.Errorf("invalid hoisted attribute %d: %w", i, err)
+		}
+
+		col := strings.ToLower(h.column())
+		if _, ok := columns[col]; ok {
+			return fmt.Errorf("invalid hoisted attribute %d: duplicate column %q", i, h.column())
+		}
+		columns[col] = struct{}{}
+	}
+
+	if err := cfg.AttributeIndex.validate(); err != nil {
+		return fmt.Errorf("invalid attribute_index: %w", err)
+	}
+
+	if err := cfg.Rollups.validate(); err != nil {
+		return fmt.Errorf("invalid rollups: %w", err)
+	}
+
+	if err := cfg.ServiceGraph.validate(); err != nil {
+		return fmt.Errorf("invalid service_graph: %w", err)
+	}
+
+	if err := cfg.Retention.validate(); err != nil {
+		return fmt.Errorf("invalid retention: %w", err)
+	}
+
 	return nil
 }
 
+func (p *PragmaConfig) validate() error {
+	if p.JournalMode != "" && !oneOf(p.JournalMode, journalModes) {
+		return fmt.Errorf("journal_mode must be one of %s, got %q", strings.Join(journalModes, ", "), p.JournalMode)
+	}
+
+	if p.Synchronous != "" && !oneOf(p.Synchronous, synchronous) {
+		return fmt.Errorf("synchronous must be one of %s, got %q", strings.Join(synchronous, ", "), p.Synchronous)
+	}
+
+	if p.BusyTimeout < 0 {
+		return errors.New("busy_timeout must be non-negative")
+	}
+
+	if p.MmapSize < 0 {
+		return errors.New("mmap_size must be non-negative")
+	}
+
+	if p.TempStore != "" && !oneOf(p.TempStore, tempStores) {
+		return fmt.Errorf("temp_store must be one of %s, got %q", strings.Join(tempStores, ", "), p.TempStore)
+	}
+
+	if p.AutoVacuum != "" && !oneOf(p.AutoVacuum, autoVacuums) {
+		return fmt.Errorf("auto_vacuum must be one of %s, got %q", strings.Join(autoVacuums, ", "), p.AutoVacuum)
+	}
+
+	return nil
+}
+
+// statements returns the PRAGMA statements to run on each new connection.
+// The values have already been validated so they can safely be formatted
+// into the statements.
+func (p *PragmaConfig) statements() []string {
+	var stmts []string
+	// auto_vacuum is set first, it can't be changed once the database has
+	// been written to.
+	if p.AutoVacuum != "" {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA auto_vacuum = %s;", strings.ToLower(p.AutoVacuum)))
+	}
+	if p.JournalMode != "" {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA journal_mode = %s;", strings.ToLower(p.JournalMode)))
+	}
+	if p.Synchronous != "" {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA synchronous = %s;", strings.ToLower(p.Synchronous)))
+	}
+	if p.BusyTimeout != 0 {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA busy_timeout = %d;", p.BusyTimeout.Milliseconds()))
+	}
+	if p.CacheSize != 0 {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA cache_size = %d;", p.CacheSize))
+	}
+	if p.MmapSize != 0 {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA mmap_size = %d;", p.MmapSize))
+	}
+	if p.TempStore != "" {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA temp_store = %s;", strings.ToLower(p.TempStore)))
+	}
+	if p.ForeignKeys {
+		stmts = append(stmts, "PRAGMA foreign_keys = ON;")
+	}
+	return stmts
+}
+
+func oneOf(v string, valid []string) bool {
+	for _, s := range valid {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
 func (cfg *Config) Unmarshal(componentParser *confmap.Conf) error {
 	if componentParser == nil {
 		return errors.New("empty config for sqlite exporter")
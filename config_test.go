@@ -4,13 +4,16 @@ package sqliteexporter
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.wperron.io/sqliteexporter/internal/metadata"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
 	"go.opentelemetry.io/collector/confmap/confmaptest"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -27,7 +30,29 @@ func TestLoadConfig(t *testing.T) {
 		{
 			id: component.NewIDWithName(metadata.Type, "1"),
 			expected: &Config{
-				Path: "./traces.db",
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
 			},
 			errorMessage: "",
 		},
@@ -36,6 +61,475 @@ func TestLoadConfig(t *testing.T) {
 			expected:     nil,
 			errorMessage: "path must be non-empty",
 		},
+		{
+			id: component.NewIDWithName(metadata.Type, "3"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   100,
+				OnConflict:         OnConflictReplace,
+				Schema:             SchemaNormalized,
+				JSONFormat:         JSONFormatJSONB,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "delete",
+					Synchronous: "full",
+					BusyTimeout: 10 * time.Second,
+					CacheSize:   -2000,
+					MmapSize:    268435456,
+					TempStore:   "memory",
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "4"),
+			expected:     nil,
+			errorMessage: "invalid pragmas: journal_mode must be one of delete, truncate, persist, memory, wal, off, got \"yolo\"",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "5"),
+			expected:     nil,
+			errorMessage: "rows_per_statement must be between 0 and 1000",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "6"),
+			expected:     nil,
+			errorMessage: "on_conflict must be one of ignore, replace or fail, got \"upsert\"",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "7"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+				HoistedAttributes: []HoistedAttribute{
+					{Source: HoistedSourceResource, Key: "deployment.environment", Column: "env"},
+					{Source: HoistedSourceSpan, Key: "http.status_code", Type: "INTEGER"},
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "8"),
+			expected:     nil,
+			errorMessage: "invalid hoisted attribute 0: column \"name\" already exists in the spans table",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "9"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+					ForeignKeys: true,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "10"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+					AutoVacuum:  "incremental",
+				},
+				Retention: RetentionConfig{
+					MaxAge:            72 * time.Hour,
+					MaxSizeBytes:      1073741824,
+					CheckInterval:     time.Minute,
+					IncrementalVacuum: true,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "11"),
+			expected:     nil,
+			errorMessage: "invalid retention: check_interval must be positive",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "12"),
+			expected:     nil,
+			errorMessage: "json_format must be one of text or jsonb, got \"bson\"",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "13"),
+			expected:     nil,
+			errorMessage: "schema must be one of denormalized or normalized, got \"snowflake\"",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "14"),
+			expected: &Config{
+				TimeoutSettings: exporterhelper.TimeoutSettings{Timeout: 30 * time.Second},
+				QueueSettings: exporterhelper.QueueSettings{
+					Enabled:      true,
+					NumConsumers: 2,
+					QueueSize:    5000,
+				},
+				BackOffConfig: configretry.BackOffConfig{
+					Enabled:             true,
+					InitialInterval:     time.Second,
+					RandomizationFactor: 0.5,
+					Multiplier:          1.5,
+					MaxInterval:         10 * time.Second,
+					MaxElapsedTime:      2 * time.Minute,
+				},
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "15"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchDelay:      5 * time.Millisecond,
+				MaxBatchRows:       0,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "16"),
+			expected:     nil,
+			errorMessage: "max_batch_rows must be non-negative",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "17"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionNano,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "18"),
+			expected:     nil,
+			errorMessage: "timestamp_precision must be one of us or ns, got \"ms\"",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "19"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingOTLPJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "20"),
+			expected:     nil,
+			errorMessage: "attribute_encoding must be one of json or otlp_json, got \"proto\"",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "21"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				AttributeIndex: AttributeIndexConfig{
+					Enabled:              true,
+					Keys:                 []string{"user.id", "http.route"},
+					MaxAttributesPerSpan: 16,
+				},
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "22"),
+			expected:     nil,
+			errorMessage: "invalid attribute_index: max_attributes_per_span must be non-negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "23"),
+			expected:     nil,
+			errorMessage: "invalid hoisted attribute 0: column \"depth\" already exists in the spans table",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "24"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+				Rollups: RollupsConfig{
+					Enabled:     true,
+					BucketWidth: 5 * time.Minute,
+					Histogram: RollupHistogramConfig{
+						Type:       RollupHistogramExponential,
+						Boundaries: defaultRollupsConfig().Histogram.Boundaries,
+						Scale:      4,
+					},
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "25"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				ServiceGraph: ServiceGraphConfig{
+					BucketWidth: time.Minute,
+				},
+				Rollups: RollupsConfig{
+					Enabled:     true,
+					BucketWidth: time.Minute,
+					Histogram: RollupHistogramConfig{
+						Type:       RollupHistogramExplicit,
+						Boundaries: []time.Duration{10 * time.Millisecond, 100 * time.Millisecond, time.Second},
+						Scale:      3,
+					},
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "26"),
+			expected:     nil,
+			errorMessage: "invalid rollups: histogram boundaries must be positive and increasing",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "27"),
+			expected:     nil,
+			errorMessage: "invalid rollups: bucket_width must be at least 1s",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "28"),
+			expected: &Config{
+				TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+				QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+				BackOffConfig:      defaultBackOffConfig(),
+				Path:               "./traces.db",
+				RowsPerStatement:   1,
+				OnConflict:         OnConflictIgnore,
+				Schema:             SchemaDenormalized,
+				JSONFormat:         JSONFormatText,
+				MaxBatchRows:       10000,
+				TimestampPrecision: TimestampPrecisionMicro,
+				AttributeEncoding:  AttributeEncodingJSON,
+				Pragmas: PragmaConfig{
+					JournalMode: "wal",
+					Synchronous: "normal",
+					BusyTimeout: 5 * time.Second,
+				},
+				Retention: RetentionConfig{
+					CheckInterval: 5 * time.Minute,
+				},
+				Rollups: defaultRollupsConfig(),
+				ServiceGraph: ServiceGraphConfig{
+					Enabled:     true,
+					BucketWidth: 10 * time.Minute,
+				},
+			},
+			errorMessage: "",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "29"),
+			expected:     nil,
+			errorMessage: "invalid service_graph: bucket_width must be at least 1s",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.id.String(), func(t *testing.T) {
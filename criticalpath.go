@@ -0,0 +1,92 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.wperron.io/sqliteexporter/internal/critpath"
+)
+
+const (
+	selectCriticalPathSpansQ = `SELECT span_id, parent_span_id, coalesce(start_time_ns, start_time * 1000), coalesce(end_time_ns, end_time * 1000) FROM spans WHERE trace_id = ?;`
+	deleteCriticalPathQ      = `DELETE FROM critical_path WHERE trace_id = ?;`
+	insertCriticalPathQ      = `INSERT INTO critical_path (trace_id, position, span_id, start_time_ns, end_time_ns) VALUES (?, ?, ?, ?, ?);`
+)
+
+// criticalPathWriter rewrites the critical_path rows of traces from their
+// stored spans. Unlike the other data derived from the spans of a trace, the
+// critical path is computed in Go, its statements are prepared once for all
+// the traces of a transaction.
+type criticalPathWriter struct {
+	spans, delete, insert *sql.Stmt
+}
+
+func newCriticalPathWriter(ctx context.Context, tx *sql.Tx) (*criticalPathWriter, error) {
+	w := &criticalPathWriter{}
+	for _, s := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&w.spans, selectCriticalPathSpansQ},
+		{&w.delete, deleteCriticalPathQ},
+		{&w.insert, insertCriticalPathQ},
+	} {
+		stmt, err := tx.PrepareContext(ctx, s.query)
+		if err != nil {
+			w.close()
+			return nil, fmt.Errorf("failed to prepare critical path stmt: %w", err)
+		}
+		*s.stmt = stmt
+	}
+	return w, nil
+}
+
+func (w *criticalPathWriter) close() {
+	for _, stmt := range []*sql.Stmt{w.spans, w.delete, w.insert} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// update replaces the critical path of a trace, it is deleted when the trace
+// has no root span left.
+func (w *criticalPathWriter) update(ctx context.Context, traceID []byte) error {
+	spans, err := w.readSpans(ctx, traceID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.delete.ExecContext(ctx, traceID); err != nil {
+		return err
+	}
+	for i, seg := range critpath.Compute(spans) {
+		if _, err := w.insert.ExecContext(ctx, traceID, i, seg.SpanID[:], seg.Start, seg.End); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *criticalPathWriter) readSpans(ctx context.Context, traceID []byte) ([]critpath.Span, error) {
+	rows, err := w.spans.QueryContext(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spans []critpath.Span
+	for rows.Next() {
+		var s critpath.Span
+		var spanID, parentID []byte
+		if err := rows.Scan(&spanID, &parentID, &s.Start, &s.End); err != nil {
+			return nil, err
+		}
+		copy(s.SpanID[:], spanID)
+		copy(s.ParentSpanID[:], parentID)
+		spans = append(spans, s)
+	}
+	return spans, rows.Err()
+}
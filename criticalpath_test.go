@@ -0,0 +1,100 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// readSelfTimes returns the self time of every span, keyed by span name.
+func readSelfTimes(t *testing.T, db *sql.DB) map[string]time.Duration {
+	rows, err := db.Query("select name, self_time_ns from spans;")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	selfTimes := make(map[string]time.Duration)
+	for rows.Next() {
+		var name string
+		var ns int64
+		require.NoError(t, rows.Scan(&name, &ns))
+		selfTimes[name] = time.Duration(ns)
+	}
+	require.NoError(t, rows.Err())
+	return selfTimes
+}
+
+// readCriticalPath returns the segments of the critical path of the trace,
+// as the span name and the offsets of the segment from start.
+func readCriticalPath(t *testing.T, db *sql.DB, start time.Time) []string {
+	rows, err := db.Query(`select spans.name, critical_path.start_time_ns, critical_path.end_time_ns from critical_path
+join spans on spans.trace_id = critical_path.trace_id and spans.span_id = critical_path.span_id
+order by critical_path.position;`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var path []string
+	for rows.Next() {
+		var name string
+		var s, e int64
+		require.NoError(t, rows.Scan(&name, &s, &e))
+		path = append(path, fmt.Sprintf("%s %s-%s", name, time.Unix(0, s).Sub(start), time.Unix(0, e).Sub(start)))
+	}
+	require.NoError(t, rows.Err())
+	return path
+}
+
+func Test_ExporterCriticalPath(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	ms := time.Millisecond
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{})
+
+	// checkout-2 and cart-3 run concurrently, payments-4 outlives the root.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 2, 1, start.Add(10*ms), 70*ms, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("cart", 3, 1, start.Add(20*ms), 40*ms, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 4, 1, start.Add(70*ms), 80*ms, ptrace.StatusCodeUnset)))
+	assert.Empty(t, readCriticalPath(t, db, start))
+
+	// the root arrives last.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, start, 100*ms, ptrace.StatusCodeUnset)))
+	assert.Equal(t, map[string]time.Duration{
+		"frontend-1": 10 * ms,
+		"checkout-2": 70 * ms,
+		"cart-3":     40 * ms,
+		"payments-4": 80 * ms,
+	}, readSelfTimes(t, db))
+	assert.Equal(t, []string{
+		"frontend-1 0s-10ms",
+		"checkout-2 10ms-70ms",
+		"payments-4 70ms-100ms",
+	}, readCriticalPath(t, db, start))
+}
+
+func Test_ExporterPruneCriticalPath(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+
+	// the child started before its parent and is old enough to be pruned,
+	// the root isn't.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, now.Add(-30*time.Minute), 30*time.Minute, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 2, 1, now.Add(-2*time.Hour), 100*time.Minute, ptrace.StatusCodeUnset)))
+	assert.Equal(t, []string{
+		"payments-2 -30m0s--20m0s",
+		"frontend-1 -20m0s-0s",
+	}, readCriticalPath(t, db, now))
+
+	require.NoError(t, ex.prune(ctx, now))
+
+	assert.Equal(t, map[string]time.Duration{"frontend-1": 30 * time.Minute}, readSelfTimes(t, db))
+	assert.Equal(t, []string{"frontend-1 -30m0s-0s"}, readCriticalPath(t, db, now))
+}
@@ -5,36 +5,100 @@ import (
 	"context"
 	"database/sql"
 	"embed"
-	"errors"
 	"fmt"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/sqlite3"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
 
 	"go.wperron.io/sqliteexporter/internal/metadata"
+	"go.wperron.io/sqliteexporter/internal/sharedcomponent"
+	"go.wperron.io/sqliteexporter/internal/sqlitedb"
 )
 
 //go:embed migrations/*.sql
 var migrations embed.FS
 
+// exporters holds the sqlite exporters currently in use, keyed by their
+// config, so that the traces and metrics pipelines of the same exporter write
+// to the database through a single connection.
+var exporters = sharedcomponent.NewMap[*Config, *sqliteExporter]()
+
 func NewFactory() exporter.Factory {
 	return exporter.NewFactory(
 		metadata.Type,
 		createDefaultConfig,
 		exporter.WithTraces(createTracesExporter, metadata.TracesStability),
-		exporter.WithMetrics(nil, metadata.MetricsStability),
-		exporter.WithLogs(nil, metadata.LogsStability),
+		exporter.WithMetrics(createMetricsExporter, metadata.MetricsStability),
+		exporter.WithLogs(createLogsExporter, metadata.LogsStability),
 	)
 }
 
 func createDefaultConfig() component.Config {
-	return &Config{}
+	return &Config{
+		// a local database either accepts a write quickly or is busy for a
+		// short while, during a checkpoint for example, so batches are
+		// retried sooner and given up on earlier than for a remote backend.
+		TimeoutSettings:    exporterhelper.TimeoutSettings{Timeout: 10 * time.Second},
+		QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+		BackOffConfig:      defaultBackOffConfig(),
+		RowsPerStatement:   1,
+		OnConflict:         OnConflictIgnore,
+		Schema:             SchemaDenormalized,
+		JSONFormat:         JSONFormatText,
+		MaxBatchRows:       10000,
+		TimestampPrecision: TimestampPrecisionMicro,
+		AttributeEncoding:  AttributeEncodingJSON,
+		Pragmas: PragmaConfig{
+			JournalMode: "wal",
+			Synchronous: "normal",
+			BusyTimeout: 5 * time.Second,
+		},
+		Retention: RetentionConfig{
+			CheckInterval: 5 * time.Minute,
+		},
+		Rollups: defaultRollupsConfig(),
+		ServiceGraph: ServiceGraphConfig{
+			BucketWidth: time.Minute,
+		},
+	}
+}
+
+func defaultRollupsConfig() RollupsConfig {
+	return RollupsConfig{
+		BucketWidth: time.Minute,
+		Histogram: RollupHistogramConfig{
+			Type: RollupHistogramExplicit,
+			Boundaries: []time.Duration{
+				5 * time.Millisecond,
+				10 * time.Millisecond,
+				25 * time.Millisecond,
+				50 * time.Millisecond,
+				100 * time.Millisecond,
+				250 * time.Millisecond,
+				500 * time.Millisecond,
+				time.Second,
+				2500 * time.Millisecond,
+				5 * time.Second,
+				10 * time.Second,
+			},
+			Scale: 3,
+		},
+	}
+}
+
+func defaultBackOffConfig() configretry.BackOffConfig {
+	cfg := configretry.NewDefaultBackOffConfig()
+	cfg.InitialInterval = 100 * time.Millisecond
+	cfg.MaxInterval = 5 * time.Second
+	cfg.MaxElapsedTime = time.Minute
+	return cfg
 }
 
 func createTracesExporter(
@@ -42,77 +106,177 @@ func createTracesExporter(
 	set exporter.CreateSettings,
 	cfg component.Config,
 ) (exporter.Traces, error) {
-	conf := cfg.(*Config)
-
-	se, err := newSqliteExporter(conf)
+	se, err := loadOrCreateExporter(cfg.(*Config), set.Logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create sqlite exporter: %w", err)
+		return nil, err
 	}
 
+	c := cfg.(*Config)
 	return exporterhelper.NewTracesExporter(
 		ctx, set, cfg,
-		se.ConsumeTraces,
+		se.Unwrap().ConsumeTraces,
+		exporterhelper.WithTimeout(c.TimeoutSettings),
+		exporterhelper.WithRetry(c.BackOffConfig),
+		exporterhelper.WithQueue(c.QueueSettings),
 		exporterhelper.WithStart(se.Start),
 		exporterhelper.WithShutdown(se.Shutdown),
 		exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
 	)
 }
 
-func newSqliteExporter(cfg *Config) (*sqliteExporter, error) {
-	db, err := sql.Open("sqlite3", cfg.Path)
+func createMetricsExporter(
+	ctx context.Context,
+	set exporter.CreateSettings,
+	cfg component.Config,
+) (exporter.Metrics, error) {
+	se, err := loadOrCreateExporter(cfg.(*Config), set.Logger)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't open sqlite3 database: %w", err)
+		return nil, err
 	}
 
+	c := cfg.(*Config)
+	return exporterhelper.NewMetricsExporter(
+		ctx, set, cfg,
+		se.Unwrap().ConsumeMetrics,
+		exporterhelper.WithTimeout(c.TimeoutSettings),
+		exporterhelper.WithRetry(c.BackOffConfig),
+		exporterhelper.WithQueue(c.QueueSettings),
+		exporterhelper.WithStart(se.Start),
+		exporterhelper.WithShutdown(se.Shutdown),
+		exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
+	)
+}
+
+func createLogsExporter(
+	ctx context.Context,
+	set exporter.CreateSettings,
+	cfg component.Config,
+) (exporter.Logs, error) {
+	se, err := loadOrCreateExporter(cfg.(*Config), set.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cfg.(*Config)
+	return exporterhelper.NewLogsExporter(
+		ctx, set, cfg,
+		se.Unwrap().ConsumeLogs,
+		exporterhelper.WithTimeout(c.TimeoutSettings),
+		exporterhelper.WithRetry(c.BackOffConfig),
+		exporterhelper.WithQueue(c.QueueSettings),
+		exporterhelper.WithStart(se.Start),
+		exporterhelper.WithShutdown(se.Shutdown),
+		exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
+	)
+}
+
+func loadOrCreateExporter(cfg *Config, logger *zap.Logger) (*sharedcomponent.Component[*sqliteExporter], error) {
+	se, err := exporters.LoadOrStore(cfg, func() (*sqliteExporter, error) {
+		se, err := newSqliteExporter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		se.logger = logger
+		return se, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlite exporter: %w", err)
+	}
+	return se, nil
+}
+
+func newSqliteExporter(cfg *Config) (*sqliteExporter, error) {
+	db := openDB(cfg)
+
 	// IMPORTANT: database/sql opens a connection pool by default, but sqlite
 	// only allows a single connection to be open at the same time.
 	db.SetMaxOpenConns(1)
 
+	return newSqliteExporterWithDB(db, cfg)
+}
+
+func newSqliteExporterWithDB(db *sql.DB, cfg *Config) (*sqliteExporter, error) {
 	if err := doMigrate(db); err != nil {
 		return nil, err
 	}
 
-	return &sqliteExporter{
-		db: db,
-	}, nil
-}
+	if err := ensureHoistedColumns(context.Background(), db, cfg.HoistedAttributes); err != nil {
+		return nil, err
+	}
 
-func NewSqliteSDKTraceExporter(cfg *Config) (sdktrace.SpanExporter, error) {
-	return newSqliteExporter(cfg)
-}
+	if err := ensureJSONFormat(context.Background(), db, cfg.JSONFormat); err != nil {
+		return nil, err
+	}
 
-func NewSqliteSDKTraceExporterWithDB(db *sql.DB) (sdktrace.SpanExporter, error) {
-	if err := doMigrate(db); err != nil {
+	if err := ensureDenormalizedView(context.Background(), db); err != nil {
 		return nil, err
 	}
 
 	return &sqliteExporter{
-		db: db,
+		db:                db,
+		rowsPerStatement:  cfg.RowsPerStatement,
+		onConflict:        cfg.OnConflict,
+		hoisted:           cfg.HoistedAttributes,
+		attributeIndex:    newAttributeIndex(cfg.AttributeIndex),
+		rollups:           newRollups(cfg.Rollups),
+		serviceGraph:      newServiceGraph(cfg.ServiceGraph),
+		logger:            zap.NewNop(),
+		retention:         cfg.Retention,
+		schema:            cfg.Schema,
+		jsonFormat:        cfg.JSONFormat,
+		nanoseconds:       cfg.TimestampPrecision == TimestampPrecisionNano,
+		attributeEncoding: cfg.AttributeEncoding,
+		maxBatchDelay:     cfg.MaxBatchDelay,
+		maxBatchRows:      cfg.MaxBatchRows,
 	}, nil
 }
 
-func doMigrate(db *sql.DB) error {
-	d, err := iofs.New(migrations, "migrations")
+// openDB opens the database with a connector that applies the configured
+// pragmas on every new connection, rather than only on the first one.
+func openDB(cfg *Config) *sql.DB {
+	return sqlitedb.Open(cfg.Path, cfg.Pragmas.statements())
+}
+
+func NewSqliteSDKTraceExporter(cfg *Config) (sdktrace.SpanExporter, error) {
+	se, err := newSqliteExporter(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to open iofs migration source: %w", err)
+		return nil, err
 	}
+	se.start()
+	return se, nil
+}
 
-	dr, err := sqlite3.WithInstance(db, &sqlite3.Config{
-		MigrationsTable: "schema_migrations_sqliteexporter",
-	})
+// NewSqliteSDKTraceExporterWithDB returns a span exporter writing to an already
+// opened database, using the default configuration.
+func NewSqliteSDKTraceExporterWithDB(db *sql.DB) (sdktrace.SpanExporter, error) {
+	se, err := newSqliteExporterWithDB(db, createDefaultConfig().(*Config))
 	if err != nil {
-		return fmt.Errorf("failed to initialize sqlite3 migrate driver: %w", err)
+		return nil, err
 	}
+	se.start()
+	return se, nil
+}
 
-	m, err := migrate.NewWithInstance("iofs", d, "sqliteexporter", dr)
+func NewSqliteSDKLogExporter(cfg *Config) (sdklog.Exporter, error) {
+	se, err := newSqliteExporter(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to initialize db migrate: %w", err)
+		return nil, err
 	}
+	se.start()
+	return sdkLogExporter{se}, nil
+}
 
-	err = m.Up()
-	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return fmt.Errorf("failed to roll up migrations: %w", err)
+// NewSqliteSDKLogExporterWithDB returns a log exporter writing to an already
+// opened database, using the default configuration.
+func NewSqliteSDKLogExporterWithDB(db *sql.DB) (sdklog.Exporter, error) {
+	se, err := newSqliteExporterWithDB(db, createDefaultConfig().(*Config))
+	if err != nil {
+		return nil, err
 	}
+	se.start()
+	return sdkLogExporter{se}, nil
+}
 
-	return nil
+func doMigrate(db *sql.DB) error {
+	return sqlitedb.Migrate(db, migrations, "migrations", "schema_migrations_sqliteexporter")
 }
@@ -3,13 +3,23 @@ package sqliteexporter
 
 import (
 	"context"
+	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/extension"
+	"go.uber.org/zap"
+
+	"go.wperron.io/sqliteexporter/internal/metadata"
+	"go.wperron.io/sqliteexporter/sqlitestorage"
 )
 
 func TestCreateDefaultConfig(t *testing.T) {
@@ -18,18 +28,205 @@ func TestCreateDefaultConfig(t *testing.T) {
 	assert.NoError(t, componenttest.CheckConfigStruct(cfg))
 }
 
-func Test_createTracesExporter(t *testing.T) {
+func Test_createExporter(t *testing.T) {
+	tests := []struct {
+		name   string
+		create func(context.Context, exporter.CreateSettings, component.Config) (component.Component, error)
+	}{
+		{
+			name: "traces",
+			create: func(ctx context.Context, set exporter.CreateSettings, cfg component.Config) (component.Component, error) {
+				return createTracesExporter(ctx, set, cfg)
+			},
+		},
+		{
+			name: "metrics",
+			create: func(ctx context.Context, set exporter.CreateSettings, cfg component.Config) (component.Component, error) {
+				return createMetricsExporter(ctx, set, cfg)
+			},
+		},
+		{
+			name: "logs",
+			create: func(ctx context.Context, set exporter.CreateSettings, cfg component.Config) (component.Component, error) {
+				return createLogsExporter(ctx, set, cfg)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Path: filepath.Join(t.TempDir(), tt.name+".db"),
+			}
+
+			exp, err := tt.create(context.Background(), exportertest.NewNopCreateSettings(), cfg)
+			assert.NoError(t, err)
+			require.NotNil(t, exp)
+
+			// the exporter is shared, shutting it down closes the database and
+			// removes it from the shared exporters.
+			require.NoError(t, exp.Shutdown(context.Background()))
+		})
+	}
+}
+
+func Test_sharedExporter(t *testing.T) {
 	cfg := &Config{
-		Path: "./traces.db",
+		Path: "./shared.db",
+	}
+	defer os.Remove("./shared.db")
+
+	texp, err := createTracesExporter(context.Background(), exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	mexp, err := createMetricsExporter(context.Background(), exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	// every pipeline takes a reference on the shared exporter, the one taken
+	// here is released right away.
+	se, err := loadOrCreateExporter(cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, se.Shutdown(context.Background()))
+
+	require.NoError(t, texp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, mexp.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, texp.Shutdown(context.Background()))
+	require.NoError(t, mexp.Shutdown(context.Background()))
+
+	// both pipelines used the same exporter, which is gone after shutdown
+	se2, err := loadOrCreateExporter(cfg, zap.NewNop())
+	require.NoError(t, err)
+	assert.NotSame(t, se, se2)
+	require.NoError(t, se2.Shutdown(context.Background()))
+}
+
+func Test_sharedExporterDrainsQueue(t *testing.T) {
+	ctx := context.Background()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = filepath.Join(t.TempDir(), "queue.db")
+
+	texp, err := createTracesExporter(ctx, exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	mexp, err := createMetricsExporter(ctx, exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, texp.Start(ctx, componenttest.NewNopHost()))
+	require.NoError(t, mexp.Start(ctx, componenttest.NewNopHost()))
+
+	// the metrics pipeline keeps queueing data after the traces pipeline was
+	// shut down, and drains its queue into the database on shutdown.
+	require.NoError(t, texp.Shutdown(ctx))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, mexp.ConsumeMetrics(ctx, oldMetrics(time.Now())))
+	}
+	require.NoError(t, mexp.Shutdown(ctx))
+
+	db, err := sql.Open("sqlite3", cfg.Path)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, 10, countRows(t, db, "metrics_gauge"))
+}
+
+// storageHost is a host exposing a storage extension to the exporters.
+type storageHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h storageHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+func Test_createExporterPersistentQueue(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	storageID := component.NewID("sqlite_storage")
+	storageCfg := sqlitestorage.NewFactory().CreateDefaultConfig().(*sqlitestorage.Config)
+	storageCfg.Path = filepath.Join(dir, "queue.db")
+	ext, err := sqlitestorage.NewFactory().CreateExtension(ctx, extension.CreateSettings{
+		ID:                storageID,
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+	}, storageCfg)
+	require.NoError(t, err)
+	host := storageHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[component.ID]component.Component{storageID: ext},
+	}
+	require.NoError(t, ext.Start(ctx, host))
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = filepath.Join(dir, "traces.db")
+	cfg.QueueSettings.StorageID = &storageID
+
+	set := exportertest.NewNopCreateSettings()
+	set.ID = component.NewID(metadata.Type)
+	exp, err := createTracesExporter(ctx, set, cfg)
+	require.NoError(t, err)
+	require.NoError(t, exp.Start(ctx, host))
+	require.NoError(t, exp.ConsumeTraces(ctx, benchmarkTraces(0, 10)))
+
+	db, err := sql.Open("sqlite3", cfg.Path)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Eventually(t, func() bool {
+		return countRows(t, db, "spans") == 10
+	}, 5*time.Second, 10*time.Millisecond)
+
+	queue, err := sql.Open("sqlite3", storageCfg.Path)
+	require.NoError(t, err)
+	defer queue.Close()
+	// the queue of the exporter is stored under its own client name.
+	var clients int
+	require.NoError(t, queue.QueryRow("SELECT count(DISTINCT client) FROM storage WHERE client = 'exporter_sqlite__traces';").Scan(&clients))
+	assert.Equal(t, 1, clients)
+
+	require.NoError(t, exp.Shutdown(ctx))
+	require.NoError(t, ext.Shutdown(ctx))
+}
+
+func Test_openDBAppliesPragmas(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = "./pragmas.db"
+	cfg.Pragmas.ForeignKeys = true
+	defer os.Remove("./pragmas.db")
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	// force two distinct connections to make sure the pragmas are applied on
+	// every one of them, not just the first.
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var journalMode string
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA journal_mode;").Scan(&journalMode))
+		assert.Equal(t, "wal", journalMode)
+
+		var synchronous, busyTimeout, foreignKeys int
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA synchronous;").Scan(&synchronous))
+		assert.Equal(t, 1, synchronous) // NORMAL
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA busy_timeout;").Scan(&busyTimeout))
+		assert.Equal(t, 5000, busyTimeout)
+		require.NoError(t, conn.QueryRowContext(ctx, "PRAGMA foreign_keys;").Scan(&foreignKeys))
+		assert.Equal(t, 1, foreignKeys)
 	}
+}
+
+func Test_NewSqliteSDKTraceExporterWithDB(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
 
-	exp, err := createTracesExporter(
-		context.Background(),
-		exportertest.NewNopCreateSettings(),
-		cfg,
-	)
-	assert.NoError(t, err)
-	require.NotNil(t, exp)
+	exp, err := NewSqliteSDKTraceExporterWithDB(db)
+	require.NoError(t, err)
+	ex := exp.(*sqliteExporter)
+	assert.Equal(t, OnConflictIgnore, ex.onConflict)
 
-	os.Remove("./traces.db")
+	// with the default on_conflict policy, retrying a batch is a no-op
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 2)))
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 2)))
+	require.NoError(t, ex.Shutdown(ctx))
 }
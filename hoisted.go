@@ -0,0 +1,168 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// ensureHoistedColumns adds the columns of the hoisted attributes, and an index
+// on each one of them, to the spans table when they don't exist yet. Columns
+// that already exist must have been added by a previous hoist with the same
+// type. Columns are added, indexed and recorded in a single transaction so
+// that a column is never left half-added.
+func ensureHoistedColumns(ctx context.Context, db *sql.DB, hoisted []HoistedAttribute) error {
+	if len(hoisted) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := queryColumns(ctx, tx, "SELECT name, type FROM pragma_table_info('spans');")
+	if err != nil {
+		return fmt.Errorf("failed to list columns of the spans table: %w", err)
+	}
+
+	previous, err := queryColumns(ctx, tx, "SELECT column_name, column_type FROM hoisted_columns;")
+	if err != nil {
+		return fmt.Errorf("failed to list hoisted columns: %w", err)
+	}
+
+	for _, h := range hoisted {
+		col := h.column()
+		key := strings.ToLower(col)
+		if typ, ok := existing[key]; ok {
+			if _, ok := previous[key]; !ok {
+				return fmt.Errorf("column %q already exists in the spans table", col)
+			}
+			if !strings.EqualFold(typ, h.columnType()) {
+				return fmt.Errorf("column %q already exists in the spans table with type %s, not %s", col, typ, h.columnType())
+			}
+			continue
+		}
+
+		// column names and types are validated with the config, so they are
+		// safe to use in the statements.
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE spans ADD COLUMN "%s" %s DEFAULT NULL;`, col, h.columnType())); err != nil {
+			return fmt.Errorf("failed to add column %q to the spans table: %w", col, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "spans_%s_idx" ON spans("%s");`, col, col)); err != nil {
+			return fmt.Errorf("failed to index column %q of the spans table: %w", col, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO hoisted_columns (column_name, column_type) VALUES (?, ?);", col, h.columnType()); err != nil {
+			return fmt.Errorf("failed to record hoisted column %q: %w", col, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// queryColumns returns the column types returned by a query listing column names
+// and types, keyed by the lowercase column name.
+func queryColumns(ctx context.Context, tx *sql.Tx, query string) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]string)
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, err
+		}
+		types[strings.ToLower(name)] = typ
+	}
+	return types, rows.Err()
+}
+
+// withHoisted returns a copy of the spec with the hoisted columns appended.
+func (s insertSpec) withHoisted(hoisted []HoistedAttribute) insertSpec {
+	if len(hoisted) == 0 {
+		return s
+	}
+
+	columns := make([]string, 0, len(s.columns)+len(hoisted))
+	columns = append(columns, s.columns...)
+	values := make([]string, 0, len(s.values)+len(hoisted))
+	values = append(values, s.values...)
+	for _, h := range hoisted {
+		columns = append(columns, `"`+h.column()+`"`)
+		values = append(values, "?")
+	}
+
+	s.columns = columns
+	s.values = values
+	return s
+}
+
+// hoistedValues returns the values of the hoisted attributes of a span, read
+// from either the resource or the span attributes.
+func hoistedValues(hoisted []HoistedAttribute, resource, span pcommon.Map) []any {
+	values := make([]any, len(hoisted))
+	for i, h := range hoisted {
+		attrs := span
+		if h.Source == HoistedSourceResource {
+			attrs = resource
+		}
+		if v, ok := attrs.Get(h.Key); ok {
+			values[i] = h.value(v)
+		}
+	}
+	return values
+}
+
+// value converts the attribute value to the type of the column. Values that
+// can't be converted are stored as NULL.
+func (h HoistedAttribute) value(v pcommon.Value) any {
+	switch h.columnType() {
+	case "INTEGER":
+		switch v.Type() {
+		case pcommon.ValueTypeInt:
+			return v.Int()
+		case pcommon.ValueTypeDouble:
+			// only integral values are converted, anything else would be
+			// truncated.
+			if f := v.Double(); f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+				return int64(f)
+			}
+		case pcommon.ValueTypeBool:
+			return v.Bool()
+		case pcommon.ValueTypeStr:
+			if i, err := strconv.ParseInt(v.Str(), 10, 64); err == nil {
+				return i
+			}
+		}
+		return nil
+	case "REAL":
+		switch v.Type() {
+		case pcommon.ValueTypeInt:
+			return float64(v.Int())
+		case pcommon.ValueTypeDouble:
+			return v.Double()
+		case pcommon.ValueTypeStr:
+			if f, err := strconv.ParseFloat(v.Str(), 64); err == nil {
+				return f
+			}
+		}
+		return nil
+	default:
+		return v.AsString()
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func Test_ExporterHoistedAttributes(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.HoistedAttributes = []HoistedAttribute{
+		{Source: HoistedSourceResource, Key: "host.name", Column: "host"},
+		{Source: HoistedSourceSpan, Key: "http.status_code", Type: "INTEGER"},
+		{Source: HoistedSourceSpan, Key: "http.method"},
+		{Source: HoistedSourceSpan, Key: "missing.key"},
+	}
+
+	ex, err := newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+
+	// creating the exporter again on the same database is a no-op
+	ex, err = newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+
+	var indexes int
+	err = db.QueryRow(`select count(1) from pragma_index_list('spans') where name in
+		('spans_host_idx', 'spans_http_status_code_idx', 'spans_http_method_idx', 'spans_missing_key_idx');`).Scan(&indexes)
+	require.NoError(t, err)
+	assert.Equal(t, 4, indexes)
+
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 2)))
+
+	var (
+		host, method string
+		statusCode   int64
+		missing      sql.NullString
+	)
+	err = db.QueryRow(`select host, http_status_code, http_method, missing_key from spans limit 1;`).
+		Scan(&host, &statusCode, &method, &missing)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", host)
+	assert.Equal(t, int64(200), statusCode)
+	assert.Equal(t, "GET", method)
+	assert.False(t, missing.Valid)
+}
+
+func Test_ensureHoistedColumnsExisting(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	require.NoError(t, doMigrate(db))
+
+	// leftover column of the microsecond timestamps migration
+	err = ensureHoistedColumns(ctx, db, []HoistedAttribute{{Source: HoistedSourceSpan, Key: "k", Column: "start_time_bak"}})
+	assert.EqualError(t, err, "column \"start_time_bak\" already exists in the spans table")
+
+	require.NoError(t, ensureHoistedColumns(ctx, db, []HoistedAttribute{{Source: HoistedSourceSpan, Key: "k", Column: "hoisted"}}))
+	err = ensureHoistedColumns(ctx, db, []HoistedAttribute{{Source: HoistedSourceSpan, Key: "k", Column: "hoisted", Type: "INTEGER"}})
+	assert.EqualError(t, err, "column \"hoisted\" already exists in the spans table with type TEXT, not INTEGER")
+}
+
+func Test_ensureHoistedColumnsRollback(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	require.NoError(t, doMigrate(db))
+
+	// a stale record makes recording the column fail after it was added.
+	_, err = db.Exec("INSERT INTO hoisted_columns (column_name, column_type) VALUES ('hoisted', 'TEXT');")
+	require.NoError(t, err)
+
+	hoisted := []HoistedAttribute{{Source: HoistedSourceSpan, Key: "k", Column: "hoisted"}}
+	require.Error(t, ensureHoistedColumns(ctx, db, hoisted))
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(1) FROM pragma_table_info('spans') WHERE name = 'hoisted';").Scan(&count))
+	assert.Equal(t, 0, count, "the column must not be left behind")
+
+	_, err = db.Exec("DELETE FROM hoisted_columns;")
+	require.NoError(t, err)
+	require.NoError(t, ensureHoistedColumns(ctx, db, hoisted))
+}
+
+func TestHoistedAttributeValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		value pcommon.Value
+		want  any
+	}{
+		{name: "text from string", typ: "TEXT", value: pcommon.NewValueStr("prod"), want: "prod"},
+		{name: "text from int", typ: "", value: pcommon.NewValueInt(42), want: "42"},
+		{name: "integer from int", typ: "INTEGER", value: pcommon.NewValueInt(42), want: int64(42)},
+		{name: "integer from string", typ: "integer", value: pcommon.NewValueStr("42"), want: int64(42)},
+		{name: "integer from invalid string", typ: "INTEGER", value: pcommon.NewValueStr("nope"), want: nil},
+		{name: "integer from integral double", typ: "INTEGER", value: pcommon.NewValueDouble(2), want: int64(2)},
+		{name: "integer from fractional double", typ: "INTEGER", value: pcommon.NewValueDouble(1.7), want: nil},
+		{name: "real from double", typ: "REAL", value: pcommon.NewValueDouble(1.5), want: 1.5},
+		{name: "real from int", typ: "REAL", value: pcommon.NewValueInt(2), want: float64(2)},
+		{name: "real from bool", typ: "REAL", value: pcommon.NewValueBool(true), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HoistedAttribute{Source: HoistedSourceSpan, Key: "k", Type: tt.typ}
+			assert.Equal(t, tt.want, h.value(tt.value))
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxVariables is the maximum number of host parameters in a single statement
+// for SQLite versions 3.32.0 and later.
+const maxVariables = 32766
+
+// insertSpec describes an INSERT statement: the table, its columns and the
+// SQL expression each value is bound with, `?` or `json(?)` for example.
+type insertSpec struct {
+	table   string
+	columns []string
+	values  []string
+
+	// onConflict is the conflict resolution algorithm of the statement, one of
+	// the OnConflict* constants. Empty is the same as OnConflictFail.
+	onConflict string
+}
+
+// withConflict returns a copy of the spec using the given conflict resolution.
+func (s insertSpec) withConflict(onConflict string) insertSpec {
+	s.onConflict = onConflict
+	return s
+}
+
+// withColumn returns a copy of the spec with an additional column, bound with
+// the value expression.
+func (s insertSpec) withColumn(column, value string) insertSpec {
+	columns := make([]string, 0, len(s.columns)+1)
+	columns = append(columns, s.columns...)
+	values := make([]string, 0, len(s.values)+1)
+	values = append(values, s.values...)
+
+	s.columns = append(columns, column)
+	s.values = append(values, value)
+	return s
+}
+
+// query returns the INSERT statement for the given number of rows.
+func (s insertSpec) query(rows int) string {
+	row := "(" + strings.Join(s.values, ", ") + ")"
+
+	var b strings.Builder
+	switch s.onConflict {
+	case OnConflictIgnore:
+		b.WriteString("INSERT OR IGNORE INTO ")
+	case OnConflictReplace:
+		b.WriteString("INSERT OR REPLACE INTO ")
+	default:
+		b.WriteString("INSERT INTO ")
+	}
+	b.WriteString(s.table)
+	b.WriteString(" (")
+	b.WriteString(strings.Join(s.columns, ", "))
+	b.WriteString(") VALUES ")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(row)
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+// batchInserter buffers the rows inserted in a table during a transaction and
+// writes them with multi-row INSERT statements of up to size rows. The
+// statement used for full batches is only prepared once per transaction.
+type batchInserter struct {
+	tx   *sql.Tx
+	spec insertSpec
+	size int
+
+	args []any
+	rows int
+	stmt *sql.Stmt
+
+	// parents are flushed before any row of this table is written so that
+	// rows never reference a parent row that is still buffered.
+	parents []*batchInserter
+}
+
+func newBatchInserter(tx *sql.Tx, spec insertSpec, size int) *batchInserter {
+	if size < 1 {
+		size = 1
+	}
+	if limit := maxVariables / len(spec.columns); size > limit {
+		size = limit
+	}
+
+	return &batchInserter{
+		tx:   tx,
+		spec: spec,
+		size: size,
+		args: make([]any, 0, size*len(spec.columns)),
+	}
+}
+
+// add buffers a row, writing the whole batch once it is full.
+func (b *batchInserter) add(ctx context.Context, args ...any) error {
+	if len(args) != len(b.spec.columns) {
+		return fmt.Errorf("expected %d values for table %s, got %d", len(b.spec.columns), b.spec.table, len(args))
+	}
+
+	b.args = append(b.args, args...)
+	b.rows++
+	if b.rows < b.size {
+		return nil
+	}
+
+	if err := b.flushParents(ctx); err != nil {
+		return err
+	}
+
+	if b.stmt == nil {
+		stmt, err := b.tx.PrepareContext(ctx, b.spec.query(b.size))
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s insert stmt: %w", b.spec.table, err)
+		}
+		b.stmt = stmt
+	}
+
+	if _, err := b.stmt.ExecContext(ctx, b.args...); err != nil {
+		return err
+	}
+	b.reset()
+	return nil
+}
+
+// flush writes the rows remaining in a partial batch.
+func (b *batchInserter) flush(ctx context.Context) error {
+	if b.rows == 0 {
+		return nil
+	}
+
+	if err := b.flushParents(ctx); err != nil {
+		return err
+	}
+
+	if _, err := b.tx.ExecContext(ctx, b.spec.query(b.rows), b.args...); err != nil {
+		return err
+	}
+	b.reset()
+	return nil
+}
+
+// withParent adds inserters flushed before any row of b is written.
+func (b *batchInserter) withParent(parents ...*batchInserter) *batchInserter {
+	b.parents = append(b.parents, parents...)
+	return b
+}
+
+func (b *batchInserter) flushParents(ctx context.Context) error {
+	for _, parent := range b.parents {
+		if err := parent.flush(ctx); err != nil {
+			return fmt.Errorf("failed to write %s rows: %w", parent.spec.table, err)
+		}
+	}
+	return nil
+}
+
+func (b *batchInserter) reset() {
+	clear(b.args)
+	b.args = b.args[:0]
+	b.rows = 0
+}
+
+// close releases the prepared statement, if any.
+func (b *batchInserter) close() error {
+	if b.stmt == nil {
+		return nil
+	}
+	return b.stmt.Close()
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+
+// Package critpath computes the critical path of a trace, the sequence of
+// spans the end of the trace was waiting on.
+package critpath
+
+import (
+	"bytes"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Span is the part of a stored span the critical path is computed from.
+type Span struct {
+	SpanID       pcommon.SpanID
+	ParentSpanID pcommon.SpanID
+	// Start and End are in nanoseconds since the epoch.
+	Start, End int64
+}
+
+// Segment is a time interval of the critical path spent in a span, outside
+// of any of its children on the path.
+type Segment struct {
+	SpanID pcommon.SpanID
+	// Start and End are in nanoseconds since the epoch.
+	Start, End int64
+}
+
+// Compute returns the critical path of the trace of the spans, ordered by
+// start time. The segments are contiguous and cover the root span from its
+// start to its end. The root is the earliest span without a parent, ties are
+// broken by span ID, and nil is returned when there isn't one.
+//
+// The path is walked back from the end of the root. At every point in time
+// it goes into the child that finished last among the children running at
+// that point, and stays in the parent when none of them is. Children are
+// clipped to their parent, so the parts of a child starting before or ending
+// after its parent aren't on the path, and to the part of the parent not yet
+// covered, so an async child overlapping a sibling that finished later is
+// only on the path from the start of that sibling backwards.
+func Compute(spans []Span) []Segment {
+	var root *Span
+	children := make(map[pcommon.SpanID][]*Span)
+	for i := range spans {
+		s := &spans[i]
+		if s.ParentSpanID.IsEmpty() {
+			if root == nil || s.Start < root.Start || (s.Start == root.Start && bytes.Compare(s.SpanID[:], root.SpanID[:]) < 0) {
+				root = s
+			}
+			continue
+		}
+		if s.ParentSpanID != s.SpanID {
+			children[s.ParentSpanID] = append(children[s.ParentSpanID], s)
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	var path []Segment
+	walk(root, root.Start, root.End, children, &path)
+	slices.Reverse(path)
+	return path
+}
+
+// walk appends the segments of the critical path of a span between lo and hi
+// to the path, from the latest to the earliest.
+func walk(span *Span, lo, hi int64, children map[pcommon.SpanID][]*Span, path *[]Segment) {
+	cursor := hi
+	for cursor > lo {
+		next := lastFinished(children[span.SpanID], lo, cursor)
+		if next == nil {
+			*path = append(*path, Segment{SpanID: span.SpanID, Start: lo, End: cursor})
+			return
+		}
+
+		end := min(next.End, cursor)
+		if end < cursor {
+			*path = append(*path, Segment{SpanID: span.SpanID, Start: end, End: cursor})
+		}
+		start := max(next.Start, lo)
+		walk(next, start, end, children, path)
+		cursor = start
+	}
+}
+
+// lastFinished returns the child running between lo and hi that finished
+// last, ties are broken by the latest start then by span ID. A child can't be
+// returned twice for a span since hi moves to its start once it's walked.
+func lastFinished(children []*Span, lo, hi int64) *Span {
+	var last *Span
+	for _, c := range children {
+		if c.End <= c.Start || c.Start >= hi || c.End <= lo {
+			continue
+		}
+		if last == nil {
+			last = c
+			continue
+		}
+		cEnd, lastEnd := min(c.End, hi), min(last.End, hi)
+		switch {
+		case cEnd != lastEnd:
+			if cEnd > lastEnd {
+				last = c
+			}
+		case c.Start != last.Start:
+			if c.Start > last.Start {
+				last = c
+			}
+		case bytes.Compare(c.SpanID[:], last.SpanID[:]) < 0:
+			last = c
+		}
+	}
+	return last
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package critpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func span(id, parent byte, start, end int64) Span {
+	s := Span{SpanID: pcommon.SpanID{id}, Start: start, End: end}
+	if parent != 0 {
+		s.ParentSpanID = pcommon.SpanID{parent}
+	}
+	return s
+}
+
+func seg(id byte, start, end int64) Segment {
+	return Segment{SpanID: pcommon.SpanID{id}, Start: start, End: end}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []Span
+		want  []Segment
+	}{
+		{
+			name:  "no spans",
+			spans: nil,
+			want:  nil,
+		},
+		{
+			name:  "no root",
+			spans: []Span{span(2, 1, 0, 10)},
+			want:  nil,
+		},
+		{
+			name:  "single span",
+			spans: []Span{span(1, 0, 0, 10)},
+			want:  []Segment{seg(1, 0, 10)},
+		},
+		{
+			name: "sequential children",
+			spans: []Span{
+				span(1, 0, 0, 100),
+				span(2, 1, 10, 40),
+				span(3, 1, 50, 90),
+			},
+			want: []Segment{
+				seg(1, 0, 10),
+				seg(2, 10, 40),
+				seg(1, 40, 50),
+				seg(3, 50, 90),
+				seg(1, 90, 100),
+			},
+		},
+		{
+			// the last child to finish is on the path until its start, the
+			// other one only before that.
+			name: "overlapping async children",
+			spans: []Span{
+				span(1, 0, 0, 100),
+				span(2, 1, 10, 80),
+				span(3, 1, 20, 60),
+				span(4, 1, 30, 50),
+			},
+			want: []Segment{
+				seg(1, 0, 10),
+				seg(2, 10, 80),
+				seg(1, 80, 100),
+			},
+		},
+		{
+			name: "overlapping child finishing last",
+			spans: []Span{
+				span(1, 0, 0, 100),
+				span(2, 1, 10, 50),
+				span(3, 1, 30, 90),
+			},
+			want: []Segment{
+				seg(1, 0, 10),
+				seg(2, 10, 30),
+				seg(3, 30, 90),
+				seg(1, 90, 100),
+			},
+		},
+		{
+			name: "children outside of their parent",
+			spans: []Span{
+				span(1, 0, 0, 100),
+				span(2, 1, -20, 30),
+				span(3, 1, 70, 150),
+				span(4, 3, 120, 140),
+				span(5, 1, 200, 300),
+			},
+			want: []Segment{
+				seg(2, 0, 30),
+				seg(1, 30, 70),
+				seg(3, 70, 100),
+			},
+		},
+		{
+			name: "nested",
+			spans: []Span{
+				span(1, 0, 0, 100),
+				span(2, 1, 10, 90),
+				span(3, 2, 20, 80),
+				span(4, 3, 30, 40),
+				span(5, 3, 35, 70),
+			},
+			want: []Segment{
+				seg(1, 0, 10),
+				seg(2, 10, 20),
+				seg(3, 20, 30),
+				seg(4, 30, 35),
+				seg(5, 35, 70),
+				seg(3, 70, 80),
+				seg(2, 80, 90),
+				seg(1, 90, 100),
+			},
+		},
+		{
+			name: "earliest root",
+			spans: []Span{
+				span(3, 0, 50, 60),
+				span(1, 0, 0, 10),
+				span(2, 0, 0, 20),
+			},
+			want: []Segment{seg(1, 0, 10)},
+		},
+		{
+			name: "zero duration children",
+			spans: []Span{
+				span(1, 0, 0, 10),
+				span(2, 1, 5, 5),
+			},
+			want: []Segment{seg(1, 0, 10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Compute(tt.spans))
+		})
+	}
+}
@@ -9,6 +9,6 @@ import (
 const (
 	Type             = "sqlite"
 	TracesStability  = component.StabilityLevelAlpha
-	MetricsStability = component.StabilityLevelUndefined
-	LogsStability    = component.StabilityLevelUndefined
+	MetricsStability = component.StabilityLevelAlpha
+	LogsStability    = component.StabilityLevelAlpha
 )
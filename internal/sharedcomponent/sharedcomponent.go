@@ -0,0 +1,103 @@
+// Copyright 2024 William Perron. All rights reserved. MIT license
+
+// Package sharedcomponent lets the traces, metrics and logs exporters created
+// from the same configuration share a single underlying component, and so a
+// single connection to the database file.
+package sharedcomponent
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Map keeps track of the components that are currently shared.
+type Map[K comparable, V component.Component] struct {
+	lock       sync.Mutex
+	components map[K]*Component[V]
+}
+
+func NewMap[K comparable, V component.Component]() *Map[K, V] {
+	return &Map[K, V]{
+		components: make(map[K]*Component[V]),
+	}
+}
+
+// LoadOrStore returns the component already stored for key, or creates it
+// using the create function if there is none. Every call takes a reference on
+// the component, released by a call to Shutdown.
+func (m *Map[K, V]) LoadOrStore(key K, create func() (V, error)) (*Component[V], error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if c, ok := m.components[key]; ok {
+		c.refs++
+		return c, nil
+	}
+
+	comp, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Component[V]{
+		component: comp,
+		refs:      1,
+	}
+	c.releaseFunc = func() bool {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		c.refs--
+		if c.refs > 0 {
+			return false
+		}
+		if m.components[key] == c {
+			delete(m.components, key)
+		}
+		return true
+	}
+	m.components[key] = c
+	return c, nil
+}
+
+// Component wraps a component.Component so that it is only started once, and
+// only shut down once every pipeline using it has been shut down.
+type Component[V component.Component] struct {
+	component V
+
+	startOnce   sync.Once
+	stopOnce    sync.Once
+	refs        int
+	releaseFunc func() bool
+}
+
+// Unwrap returns the original component.
+func (c *Component[V]) Unwrap() V {
+	return c.component
+}
+
+// Start starts the underlying component if it hasn't been started already.
+func (c *Component[V]) Start(ctx context.Context, host component.Host) error {
+	var err error
+	c.startOnce.Do(func() {
+		err = c.component.Start(ctx, host)
+	})
+	return err
+}
+
+// Shutdown releases a reference on the component. The last one shuts down the
+// underlying component and removes it from the Map it belongs to, so that the
+// pipelines still using it, and draining their queue into it, are not cut off
+// by the first pipeline shutting down.
+func (c *Component[V]) Shutdown(ctx context.Context) error {
+	if !c.releaseFunc() {
+		return nil
+	}
+
+	var err error
+	c.stopOnce.Do(func() {
+		err = c.component.Shutdown(ctx)
+	})
+	return err
+}
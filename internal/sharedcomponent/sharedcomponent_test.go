@@ -0,0 +1,103 @@
+// Copyright 2024 William Perron. All rights reserved. MIT license
+package sharedcomponent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+type countingComponent struct {
+	starts    int
+	shutdowns int
+}
+
+func (c *countingComponent) Start(context.Context, component.Host) error {
+	c.starts++
+	return nil
+}
+
+func (c *countingComponent) Shutdown(context.Context) error {
+	c.shutdowns++
+	return nil
+}
+
+func TestLoadOrStore(t *testing.T) {
+	m := NewMap[string, *countingComponent]()
+
+	created := 0
+	create := func() (*countingComponent, error) {
+		created++
+		return &countingComponent{}, nil
+	}
+
+	c1, err := m.LoadOrStore("a", create)
+	require.NoError(t, err)
+	c2, err := m.LoadOrStore("a", create)
+	require.NoError(t, err)
+	assert.Same(t, c1, c2)
+	assert.Equal(t, 1, created)
+
+	_, err = m.LoadOrStore("b", create)
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+}
+
+func TestLoadOrStoreError(t *testing.T) {
+	m := NewMap[string, *countingComponent]()
+	boom := errors.New("boom")
+
+	_, err := m.LoadOrStore("a", func() (*countingComponent, error) { return nil, boom })
+	assert.ErrorIs(t, err, boom)
+	assert.Empty(t, m.components)
+}
+
+func TestStartShutdownOnce(t *testing.T) {
+	ctx := context.Background()
+	m := NewMap[string, *countingComponent]()
+
+	c, err := m.LoadOrStore("a", func() (*countingComponent, error) { return &countingComponent{}, nil })
+	require.NoError(t, err)
+
+	require.NoError(t, c.Start(ctx, componenttest.NewNopHost()))
+	require.NoError(t, c.Start(ctx, componenttest.NewNopHost()))
+	assert.Equal(t, 1, c.Unwrap().starts)
+
+	require.NoError(t, c.Shutdown(ctx))
+	require.NoError(t, c.Shutdown(ctx))
+	assert.Equal(t, 1, c.Unwrap().shutdowns)
+
+	// once shut down, the component is removed and a new one gets created
+	c2, err := m.LoadOrStore("a", func() (*countingComponent, error) { return &countingComponent{}, nil })
+	require.NoError(t, err)
+	assert.NotSame(t, c, c2)
+}
+
+func TestShutdownLastReference(t *testing.T) {
+	ctx := context.Background()
+	m := NewMap[string, *countingComponent]()
+	create := func() (*countingComponent, error) { return &countingComponent{}, nil }
+
+	// two pipelines share the component
+	c, err := m.LoadOrStore("a", create)
+	require.NoError(t, err)
+	_, err = m.LoadOrStore("a", create)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Shutdown(ctx))
+	assert.Equal(t, 0, c.Unwrap().shutdowns, "the second pipeline still uses the component")
+	c2, err := m.LoadOrStore("a", create)
+	require.NoError(t, err)
+	assert.Same(t, c, c2)
+
+	require.NoError(t, c.Shutdown(ctx))
+	assert.Equal(t, 0, c.Unwrap().shutdowns)
+	require.NoError(t, c.Shutdown(ctx))
+	assert.Equal(t, 1, c.Unwrap().shutdowns)
+	assert.Empty(t, m.components)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 William Perron. All rights reserved. MIT license
+
+// Package sqlitedb opens SQLite databases and applies their migrations, for
+// the components of this module that store data in a SQLite file.
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite3 "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Open opens the database at dsn with a connector that runs the pragmas on
+// every new connection, rather than only on the first one.
+func Open(dsn string, pragmas []string) *sql.DB {
+	return sql.OpenDB(&connector{
+		dsn: dsn,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for _, p := range pragmas {
+					if _, err := conn.Exec(p, nil); err != nil {
+						return fmt.Errorf("failed to apply %q: %w", p, err)
+					}
+				}
+				return nil
+			},
+		},
+	})
+}
+
+var _ driver.Connector = (*connector)(nil)
+
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Migrate applies the up migrations found in the dir directory of fsys,
+// recording the applied version in table. Each component of the module uses
+// its own migrations table so that they can share a database file.
+func Migrate(db *sql.DB, fsys fs.FS, dir, table string) error {
+	d, err := iofs.New(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to open iofs migration source: %w", err)
+	}
+
+	dr, err := migratesqlite3.WithInstance(db, &migratesqlite3.Config{
+		MigrationsTable: table,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize sqlite3 migrate driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", d, "sqlite3", dr)
+	if err != nil {
+		return fmt.Errorf("failed to initialize db migrate: %w", err)
+	}
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to roll up migrations: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 William Perron. All rights reserved. MIT license
+package transform
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func Logs(records []sdklog.Record) plog.Logs {
+	logs := plog.NewLogs()
+	rls := logs.ResourceLogs()
+	resMap := make(map[uint64]plog.ResourceLogs)
+	scopeMap := make(map[scopeKey]plog.ScopeLogs)
+
+	for i := range records {
+		r := &records[i]
+		res := r.Resource()
+		rh := hashResource(&res)
+
+		var rl plog.ResourceLogs
+		if existing, ok := resMap[rh]; ok {
+			rl = existing
+		} else {
+			rl = rls.AppendEmpty()
+			resMap[rh] = rl
+
+			ra := transformAttributes(res.Attributes())
+			ra.CopyTo(rl.Resource().Attributes())
+		}
+
+		// the same scope can be used by several resources, scopes are only
+		// shared by the records of a single resource.
+		sk := scopeKey{resource: rh, scope: hashScope(r.InstrumentationScope())}
+
+		var sl plog.ScopeLogs
+		if scope, ok := scopeMap[sk]; ok {
+			sl = scope
+		} else {
+			sl = rl.ScopeLogs().AppendEmpty()
+			scopeMap[sk] = sl
+
+			sl.Scope().SetName(r.InstrumentationScope().Name)
+			sl.Scope().SetVersion(r.InstrumentationScope().Version)
+		}
+
+		lr := sl.LogRecords().AppendEmpty()
+		lr.SetTimestamp(pcommon.NewTimestampFromTime(r.Timestamp()))
+		lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(r.ObservedTimestamp()))
+		lr.SetSeverityNumber(plog.SeverityNumber(r.Severity()))
+		lr.SetSeverityText(r.SeverityText())
+		lr.SetTraceID(pcommon.TraceID(r.TraceID()))
+		lr.SetSpanID(pcommon.SpanID(r.SpanID()))
+		lr.SetFlags(plog.LogRecordFlags(r.TraceFlags()))
+		lr.SetDroppedAttributesCount(uint32(r.DroppedAttributes()))
+		transformLogValue(r.Body(), lr.Body())
+
+		lr.Attributes().EnsureCapacity(r.AttributesLen())
+		r.WalkAttributes(func(kv log.KeyValue) bool {
+			transformLogValue(kv.Value, lr.Attributes().PutEmpty(kv.Key))
+			return true
+		})
+	}
+
+	return logs
+}
+
+func transformLogValue(from log.Value, to pcommon.Value) {
+	switch from.Kind() {
+	case log.KindBool:
+		to.SetBool(from.AsBool())
+	case log.KindInt64:
+		to.SetInt(from.AsInt64())
+	case log.KindFloat64:
+		to.SetDouble(from.AsFloat64())
+	case log.KindString:
+		to.SetStr(from.AsString())
+	case log.KindBytes:
+		to.SetEmptyBytes().FromRaw(from.AsBytes())
+	case log.KindSlice:
+		raw := from.AsSlice()
+		s := to.SetEmptySlice()
+		s.EnsureCapacity(len(raw))
+		for _, v := range raw {
+			transformLogValue(v, s.AppendEmpty())
+		}
+	case log.KindMap:
+		raw := from.AsMap()
+		m := to.SetEmptyMap()
+		m.EnsureCapacity(len(raw))
+		for _, kv := range raw {
+			transformLogValue(kv.Value, m.PutEmpty(kv.Key))
+		}
+	}
+}
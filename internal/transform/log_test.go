@@ -0,0 +1,125 @@
+// Copyright 2024 William Perron. All rights reserved. MIT license
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/log/logtest"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransformLogs(t *testing.T) {
+	ts := time.Unix(1000, 0)
+	observed := time.Unix(1001, 0)
+	res := resource.NewWithAttributes("https://opentelemetry.io/schemas/1.24.0",
+		attribute.KeyValue{Key: "service.name", Value: attribute.StringValue("test-service")},
+	)
+	scope := &instrumentation.Scope{Name: "test-logger", Version: "0.0.1"}
+
+	records := []sdklog.Record{
+		logtest.RecordFactory{
+			Timestamp:         ts,
+			ObservedTimestamp: observed,
+			Severity:          log.SeverityError,
+			SeverityText:      "ERROR",
+			Body:              log.StringValue("something went wrong"),
+			Attributes: []log.KeyValue{
+				log.String("stringkey", "stringval"),
+				log.Int64("intkey", 123),
+				log.Float64("floatkey", 1.5),
+				log.Bool("boolkey", true),
+				log.Bytes("byteskey", []byte{0x01, 0x02}),
+				log.Slice("slicekey", log.StringValue("a"), log.IntValue(1)),
+				log.Map("mapkey", log.String("nested", "value")),
+			},
+			TraceID:              trace.TraceID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+			SpanID:               trace.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+			TraceFlags:           trace.FlagsSampled,
+			DroppedAttributes:    2,
+			Resource:             res,
+			InstrumentationScope: scope,
+		}.NewRecord(),
+		logtest.RecordFactory{
+			Timestamp:            ts,
+			Body:                 log.MapValue(log.String("msg", "structured")),
+			Resource:             res,
+			InstrumentationScope: scope,
+		}.NewRecord(),
+	}
+
+	logs := Logs(records)
+	require.Equal(t, 1, logs.ResourceLogs().Len())
+	rl := logs.ResourceLogs().At(0)
+	exp := pcommon.NewMap()
+	exp.PutStr("service.name", "test-service")
+	assert.Equal(t, exp, rl.Resource().Attributes())
+
+	require.Equal(t, 1, rl.ScopeLogs().Len())
+	sl := rl.ScopeLogs().At(0)
+	assert.Equal(t, "test-logger", sl.Scope().Name())
+	assert.Equal(t, "0.0.1", sl.Scope().Version())
+
+	require.Equal(t, 2, sl.LogRecords().Len())
+	lr := sl.LogRecords().At(0)
+	assert.Equal(t, pcommon.NewTimestampFromTime(ts), lr.Timestamp())
+	assert.Equal(t, pcommon.NewTimestampFromTime(observed), lr.ObservedTimestamp())
+	assert.Equal(t, plog.SeverityNumberError, lr.SeverityNumber())
+	assert.Equal(t, "ERROR", lr.SeverityText())
+	assert.Equal(t, "something went wrong", lr.Body().Str())
+	assert.Equal(t, pcommon.TraceID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, lr.TraceID())
+	assert.Equal(t, pcommon.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, lr.SpanID())
+	assert.Equal(t, plog.DefaultLogRecordFlags.WithIsSampled(true), lr.Flags())
+	assert.Equal(t, uint32(2), lr.DroppedAttributesCount())
+	assert.Equal(t, map[string]any{
+		"stringkey": "stringval",
+		"intkey":    int64(123),
+		"floatkey":  1.5,
+		"boolkey":   true,
+		"byteskey":  []byte{0x01, 0x02},
+		"slicekey":  []any{"a", int64(1)},
+		"mapkey":    map[string]any{"nested": "value"},
+	}, lr.Attributes().AsRaw())
+
+	lr = sl.LogRecords().At(1)
+	assert.Equal(t, pcommon.ValueTypeMap, lr.Body().Type())
+	assert.Equal(t, map[string]any{"msg": "structured"}, lr.Body().Map().AsRaw())
+	assert.True(t, lr.TraceID().IsEmpty())
+}
+
+func TestTransformLogsScopePerResource(t *testing.T) {
+	scope := &instrumentation.Scope{Name: "test-logger"}
+	res1 := resource.NewSchemaless(attribute.String("service.name", "first"))
+	res2 := resource.NewSchemaless(attribute.String("service.name", "second"))
+
+	records := []sdklog.Record{
+		logtest.RecordFactory{Body: log.StringValue("1"), Resource: res1, InstrumentationScope: scope}.NewRecord(),
+		logtest.RecordFactory{Body: log.StringValue("2"), Resource: res2, InstrumentationScope: scope}.NewRecord(),
+		logtest.RecordFactory{Body: log.StringValue("3"), Resource: res1, InstrumentationScope: scope}.NewRecord(),
+	}
+
+	logs := Logs(records)
+	require.Equal(t, 2, logs.ResourceLogs().Len())
+
+	for i, expected := range map[int][]string{0: {"1", "3"}, 1: {"2"}} {
+		rl := logs.ResourceLogs().At(i)
+		require.Equal(t, 1, rl.ScopeLogs().Len())
+		sl := rl.ScopeLogs().At(0)
+		assert.Equal(t, "test-logger", sl.Scope().Name())
+
+		var bodies []string
+		for j := 0; j < sl.LogRecords().Len(); j++ {
+			bodies = append(bodies, sl.LogRecords().At(j).Body().Str())
+		}
+		assert.Equal(t, expected, bodies)
+	}
+}
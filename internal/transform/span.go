@@ -17,34 +17,45 @@ func Spans(sdl []sdktrace.ReadOnlySpan) ptrace.Traces {
 	traces := ptrace.NewTraces()
 	rss := traces.ResourceSpans()
 	resMap := make(map[uint64]ptrace.ResourceSpans)
-	scopeMap := make(map[uint64]ptrace.ScopeSpans)
+	scopeMap := make(map[scopeKey]ptrace.ScopeSpans)
 
 	for _, s := range sdl {
+		rh := hashResource(s.Resource())
+
 		var rs ptrace.ResourceSpans
-		if r, ok := resMap[hashResource(s.Resource())]; ok {
+		if r, ok := resMap[rh]; ok {
 			rs = r
 		} else {
 			// create a new resource
 			// append it to the traces
 			// add it to the map
 			rs = rss.AppendEmpty()
-			resMap[hashResource(s.Resource())] = rs
+			resMap[rh] = rs
+
+			// the SDK never drops resource attributes, the dropped attributes
+			// count is left at 0.
+			rs.SetSchemaUrl(s.Resource().SchemaURL())
+			ra := transformAttributes(s.Resource().Attributes())
+			ra.CopyTo(rs.Resource().Attributes())
 		}
 
-		res := rs.Resource()
-		res.SetDroppedAttributesCount(0) // TODO(wperron) how can we get this number?
-		ra := transformAttributes(s.Resource().Attributes())
-		ra.CopyTo(res.Attributes())
+		// the same scope can be used by several resources, scopes are only
+		// shared by the spans of a single resource.
+		sk := scopeKey{resource: rh, scope: hashScope(s.InstrumentationScope())}
 
 		var ss ptrace.ScopeSpans
-		if scope, ok := scopeMap[hashScope(s.InstrumentationScope())]; ok {
+		if scope, ok := scopeMap[sk]; ok {
 			ss = scope
 		} else {
 			// create a new scope
 			// append it to the resource
 			// add it to the map
 			ss = rs.ScopeSpans().AppendEmpty()
-			scopeMap[hashScope(s.InstrumentationScope())] = ss
+			scopeMap[sk] = ss
+
+			ss.SetSchemaUrl(s.InstrumentationScope().SchemaURL)
+			ss.Scope().SetName(s.InstrumentationScope().Name)
+			ss.Scope().SetVersion(s.InstrumentationScope().Version)
 		}
 
 		// create a new span and fill it with the info from the readonly span
@@ -152,16 +163,28 @@ func transformAttributes(from []attribute.KeyValue) pcommon.Map {
 	return to
 }
 
+// scopeKey identifies an instrumentation scope within a resource.
+type scopeKey struct {
+	resource uint64
+	scope    uint64
+}
+
 func hashResource(res *resource.Resource) uint64 {
 	h := fnv.New64a()
 	h.Write([]byte(res.Encoded(attribute.DefaultEncoder())))
+	h.Write([]byte{0})
+	h.Write([]byte(res.SchemaURL()))
 	return h.Sum64()
 }
 
+// hashScope identifies a scope by all of its fields. Scopes of this version of
+// the SDK don't have attributes.
 func hashScope(s instrumentation.Scope) uint64 {
 	h := fnv.New64a()
 	h.Write([]byte(s.Name))
+	h.Write([]byte{0})
 	h.Write([]byte(s.SchemaURL))
+	h.Write([]byte{0})
 	h.Write([]byte(s.Version))
 	return h.Sum64()
 }
@@ -136,10 +136,14 @@ func TestTransformSpan(t *testing.T) {
 		exp.PutStr("service.name", "test-service")
 		assert.Equal(t, exp, res.Attributes())
 		assert.Equal(t, uint32(0), res.DroppedAttributesCount())
+		assert.Equal(t, "https://opentelemetry.io/schemas/1.24.0", rs.SchemaUrl())
 
 		for j := 0; j < rs.ScopeSpans().Len(); j++ {
 			require.Less(t, j, 1)
 			ss := rs.ScopeSpans().At(j)
+			assert.Equal(t, "test-tracer", ss.Scope().Name())
+			assert.Equal(t, "0.0.1", ss.Scope().Version())
+			assert.Equal(t, "https://opentelemetry.io/schemas/1.24.0", ss.SchemaUrl())
 			for k := 0; k < ss.Spans().Len(); k++ {
 				require.Less(t, k, 1)
 				span := ss.Spans().At(k)
@@ -233,3 +237,53 @@ func must[T any](v T, err error) T {
 	}
 	return v
 }
+
+func TestTransformSpansScopePerResource(t *testing.T) {
+	scope := instrumentation.Scope{Name: "test-tracer"}
+	res1 := resource.NewSchemaless(attribute.String("service.name", "first"))
+	res2 := resource.NewSchemaless(attribute.String("service.name", "second"))
+
+	spans := []sdktrace.ReadOnlySpan{
+		tracetest.SpanStub{Name: "1", Resource: res1, InstrumentationLibrary: scope}.Snapshot(),
+		tracetest.SpanStub{Name: "2", Resource: res2, InstrumentationLibrary: scope}.Snapshot(),
+		tracetest.SpanStub{Name: "3", Resource: res1, InstrumentationLibrary: scope}.Snapshot(),
+	}
+
+	traces := Spans(spans)
+	require.Equal(t, 2, traces.ResourceSpans().Len())
+
+	for i, expected := range map[int][]string{0: {"1", "3"}, 1: {"2"}} {
+		rs := traces.ResourceSpans().At(i)
+		require.Equal(t, 1, rs.ScopeSpans().Len())
+		ss := rs.ScopeSpans().At(0)
+
+		var names []string
+		for j := 0; j < ss.Spans().Len(); j++ {
+			names = append(names, ss.Spans().At(j).Name())
+		}
+		assert.Equal(t, expected, names)
+	}
+}
+
+func TestTransformSpansSchemaURL(t *testing.T) {
+	attrs := attribute.String("service.name", "test-service")
+	spans := []sdktrace.ReadOnlySpan{
+		tracetest.SpanStub{Name: "1", Resource: resource.NewWithAttributes("https://opentelemetry.io/schemas/1.24.0", attrs)}.Snapshot(),
+		tracetest.SpanStub{Name: "2", Resource: resource.NewWithAttributes("https://opentelemetry.io/schemas/1.21.0", attrs)}.Snapshot(),
+		tracetest.SpanStub{Name: "3", Resource: resource.NewWithAttributes("https://opentelemetry.io/schemas/1.24.0", attrs), InstrumentationLibrary: instrumentation.Scope{Name: "test-tracer", SchemaURL: "https://opentelemetry.io/schemas/1.21.0"}}.Snapshot(),
+	}
+
+	traces := Spans(spans)
+
+	// resources and scopes with the same attributes but different schemas
+	// aren't merged.
+	require.Equal(t, 2, traces.ResourceSpans().Len())
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.24.0", traces.ResourceSpans().At(0).SchemaUrl())
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.21.0", traces.ResourceSpans().At(1).SchemaUrl())
+
+	scopes := traces.ResourceSpans().At(0).ScopeSpans()
+	require.Equal(t, 2, scopes.Len())
+	assert.Equal(t, "", scopes.At(0).SchemaUrl())
+	assert.Equal(t, "test-tracer", scopes.At(1).Scope().Name())
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.21.0", scopes.At(1).SchemaUrl())
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonbMinVersion is the first SQLite version supporting the JSONB functions.
+var jsonbMinVersion = [3]int{3, 45, 0}
+
+// jsonColumns are the columns converted when the JSON format changes.
+var jsonColumns = map[string][]string{
+	"spans":     {"attributes", "resource_attributes", "instrumentation_library_attributes"},
+	"events":    {"attributes"},
+	"links":     {"attributes"},
+	"resources": {"attributes"},
+	"scopes":    {"attributes"},
+}
+
+// withJSONFormat returns a copy of the spec storing JSON values in the given
+// format.
+func (s insertSpec) withJSONFormat(format string) insertSpec {
+	if format != JSONFormatJSONB {
+		return s
+	}
+
+	values := make([]string, len(s.values))
+	for i, v := range s.values {
+		if v == "json(?)" {
+			v = "jsonb(?)"
+		}
+		values[i] = v
+	}
+	s.values = values
+	return s
+}
+
+// ensureJSONFormat checks that the linked SQLite supports the JSON format and
+// converts the rows written with a different format since the last start.
+func ensureJSONFormat(ctx context.Context, db *sql.DB, format string) error {
+	if format == "" {
+		format = JSONFormatText
+	}
+
+	if format == JSONFormatJSONB {
+		if err := checkSQLiteVersion(ctx, db, jsonbMinVersion); err != nil {
+			return fmt.Errorf("json_format %s is not supported: %w", JSONFormatJSONB, err)
+		}
+	}
+
+	var previous string
+	err := db.QueryRowContext(ctx, "SELECT value FROM exporter_settings WHERE key = 'json_format';").Scan(&previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read the previous json_format: %w", err)
+	}
+	if previous == "" {
+		previous = JSONFormatText
+	}
+	if previous == format {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	if err := convertJSON(ctx, tx, format); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO exporter_settings (key, value) VALUES ('json_format', ?);", format); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to save json_format: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// convertJSON rewrites the JSON columns stored in the other format.
+func convertJSON(ctx context.Context, tx *sql.Tx, format string) error {
+	fn, from := "json", "blob"
+	if format == JSONFormatJSONB {
+		fn, from = "jsonb", "text"
+	}
+
+	for table, columns := range jsonColumns {
+		for _, col := range columns {
+			q := fmt.Sprintf("UPDATE %s SET %s = %s(%s) WHERE typeof(%s) = '%s';", table, col, fn, col, col, from)
+			if _, err := tx.ExecContext(ctx, q); err != nil {
+				return fmt.Errorf("failed to convert %s.%s to %s: %w", table, col, format, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSQLiteVersion returns an error if the linked SQLite library is older
+// than minVersion.
+func checkSQLiteVersion(ctx context.Context, db *sql.DB, minVersion [3]int) error {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT sqlite_version();").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read the sqlite version: %w", err)
+	}
+
+	v, err := parseSQLiteVersion(version)
+	if err != nil {
+		return err
+	}
+
+	for i := range v {
+		if v[i] != minVersion[i] {
+			if v[i] < minVersion[i] {
+				return fmt.Errorf("sqlite %s is too old, %d.%d.%d or later is required", version, minVersion[0], minVersion[1], minVersion[2])
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func parseSQLiteVersion(version string) ([3]int, error) {
+	var v [3]int
+	parts := strings.SplitN(version, ".", 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, fmt.Errorf("invalid sqlite version %q", version)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
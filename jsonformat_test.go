@@ -0,0 +1,75 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExporterJSONFormat(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	typeOf := func(t *testing.T) (string, string) {
+		var spans, events string
+		require.NoError(t, db.QueryRow("select typeof(attributes) from spans limit 1;").Scan(&spans))
+		require.NoError(t, db.QueryRow("select typeof(attributes) from events limit 1;").Scan(&events))
+		return spans, events
+	}
+
+	cfg := createDefaultConfig().(*Config)
+	ex, err := newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 2)))
+
+	spans, events := typeOf(t)
+	assert.Equal(t, "text", spans)
+	assert.Equal(t, "text", events)
+
+	// switching to jsonb converts the existing rows on startup
+	cfg.JSONFormat = JSONFormatJSONB
+	ex, err = newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(1, 2)))
+
+	var texts int
+	require.NoError(t, db.QueryRow("select count(1) from spans where typeof(attributes) = 'text' or typeof(resource_attributes) = 'text';").Scan(&texts))
+	assert.Equal(t, 0, texts)
+	spans, events = typeOf(t)
+	assert.Equal(t, "blob", spans)
+	assert.Equal(t, "blob", events)
+
+	// the JSON functions work on both formats
+	var method string
+	require.NoError(t, db.QueryRow(`select attributes->>'$."http.method"' from spans limit 1;`).Scan(&method))
+	assert.Equal(t, "GET", method)
+
+	// and switching back converts them to text again
+	cfg.JSONFormat = JSONFormatText
+	_, err = newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+	spans, events = typeOf(t)
+	assert.Equal(t, "text", spans)
+	assert.Equal(t, "text", events)
+
+	var attrs string
+	require.NoError(t, db.QueryRow("select attributes from spans limit 1;").Scan(&attrs))
+	assert.Equal(t, `{"http.method":"GET","http.status_code":200}`, attrs)
+}
+
+func Test_parseSQLiteVersion(t *testing.T) {
+	v, err := parseSQLiteVersion("3.45.1")
+	require.NoError(t, err)
+	assert.Equal(t, [3]int{3, 45, 1}, v)
+
+	_, err = parseSQLiteVersion("three")
+	assert.Error(t, err)
+}
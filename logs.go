@@ -0,0 +1,151 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.wperron.io/sqliteexporter/internal/transform"
+)
+
+var _ sdklog.Exporter = sdkLogExporter{}
+
+// sdkLogExporter adapts the sqliteExporter to the sdklog.Exporter interface.
+// It can't be implemented on the sqliteExporter directly since the Export
+// method would be too ambiguous.
+type sdkLogExporter struct {
+	*sqliteExporter
+}
+
+// Export transforms and exports a batch of log records.
+func (e sdkLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.ConsumeLogs(ctx, transform.Logs(records))
+}
+
+// ForceFlush is a no-op, records are written as soon as they are exported.
+func (e sdkLogExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+const insertLogQ string = `INSERT INTO logs
+(
+    timestamp,
+    observed_timestamp,
+    trace_id,
+    span_id,
+    flags,
+    severity_number,
+    severity_text,
+    body,
+    attributes,
+    dropped_attributes_count,
+    __service_name,
+    resource_attributes,
+    resource_dropped_attributes_count,
+    instrumentation_library_name,
+    instrumentation_library_version,
+    instrumentation_library_attributes
+)
+VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, json(?), ?, ?, json(?), ?, ?, ?, json(?)
+);
+`
+
+func (e *sqliteExporter) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
+	return e.write(ctx, logs.LogRecordCount(), func(ctx context.Context, tx *sql.Tx) error {
+		return e.insertLogs(ctx, tx, logs)
+	})
+}
+
+func (e *sqliteExporter) insertLogs(ctx context.Context, tx *sql.Tx, logs plog.Logs) error {
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		resource := logs.ResourceLogs().At(i)
+		svc := serviceName(resource.Resource())
+
+		rattrs, err := e.marshalAttributes(resource.Resource().Attributes())
+		if err != nil {
+			return fmt.Errorf("failed to marshal resource attributes as json: %w", err)
+		}
+
+		for j := 0; j < resource.ScopeLogs().Len(); j++ {
+			scope := resource.ScopeLogs().At(j)
+			sattrs, err := e.marshalAttributes(scope.Scope().Attributes())
+			if err != nil {
+				return fmt.Errorf("failed to marshal instrumentation scope attributes as json: %w", err)
+			}
+
+			for k := 0; k < scope.LogRecords().Len(); k++ {
+				record := scope.LogRecords().At(k)
+
+				attrs, err := e.marshalAttributes(record.Attributes())
+				if err != nil {
+					return fmt.Errorf("failed to marshal attributes as json: %w", err)
+				}
+
+				body, err := logBody(record.Body())
+				if err != nil {
+					return fmt.Errorf("failed to marshal log body as json: %w", err)
+				}
+
+				// trace_id and span_id are left NULL for records emitted
+				// outside of a span.
+				var traceidbs, spanidbs []byte
+				if !record.TraceID().IsEmpty() {
+					traceidraw := [16]byte(record.TraceID())
+					traceidbs = traceidraw[:]
+				}
+				if !record.SpanID().IsEmpty() {
+					spanidraw := [8]byte(record.SpanID())
+					spanidbs = spanidraw[:]
+				}
+
+				_, err = tx.ExecContext(ctx, insertLogQ,
+					// Use microsecond precision for timestamps, same as spans
+					unixMicro(record.Timestamp().AsTime()),
+					unixMicro(record.ObservedTimestamp().AsTime()),
+					traceidbs,
+					spanidbs,
+					uint32(record.Flags()),
+					int32(record.SeverityNumber()),
+					record.SeverityText(),
+					body,
+					attrs,
+					record.DroppedAttributesCount(),
+					svc,
+					rattrs,
+					resource.Resource().DroppedAttributesCount(),
+					scope.Scope().Name(),
+					scope.Scope().Version(),
+					sattrs,
+				)
+				if err != nil {
+					return fmt.Errorf("error occured while inserting log record: %w", err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// logBody returns the value to store in the body column. String bodies are
+// stored as-is to keep them readable, any other type is encoded as JSON.
+func logBody(v pcommon.Value) (any, error) {
+	switch v.Type() {
+	case pcommon.ValueTypeEmpty:
+		return nil, nil
+	case pcommon.ValueTypeStr:
+		return v.Str(), nil
+	default:
+		b, err := json.Marshal(v.AsRaw())
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/log/logtest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_ExporterExportLogs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// manually build the exporter so we can inspect the database
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	err = doMigrate(db)
+	require.NoError(t, err)
+
+	ex := sqliteExporter{db: db}
+
+	// write a span first so the log record can be joined with it
+	testTrace := ptrace.NewTraces()
+	rs := testTrace.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "test-service")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	span.SetSpanID(pcommon.SpanID{0xee, 0xbc, 0x00, 0x00, 0x00, 0x00, 0xab, 0x01})
+	span.SetName("span1")
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-5 * time.Millisecond)))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(now))
+	require.NoError(t, ex.ConsumeTraces(ctx, testTrace))
+
+	testLogs := plog.NewLogs()
+	rl := testLogs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "test-service")
+	sl := rl.ScopeLogs().AppendEmpty()
+	sl.Scope().SetName("test-scope")
+	sl.Scope().SetVersion("v0.1.0")
+
+	lr1 := sl.LogRecords().AppendEmpty()
+	lr1.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-3 * time.Millisecond)))
+	lr1.SetObservedTimestamp(pcommon.NewTimestampFromTime(now))
+	lr1.SetSeverityNumber(plog.SeverityNumberWarn)
+	lr1.SetSeverityText("WARN")
+	lr1.Body().SetStr("cache miss")
+	lr1.Attributes().PutStr("cache.key", "user:1")
+	lr1.SetTraceID(span.TraceID())
+	lr1.SetSpanID(span.SpanID())
+	lr1.SetFlags(plog.DefaultLogRecordFlags.WithIsSampled(true))
+
+	lr2 := sl.LogRecords().AppendEmpty()
+	lr2.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	lr2.Body().SetEmptyMap().PutInt("status", 200)
+
+	err = ex.ConsumeLogs(ctx, testLogs)
+	require.NoError(t, err)
+
+	var total int
+	err = db.QueryRow("select count(1) from logs;").Scan(&total)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	var (
+		ts, observed, flags, severity int64
+		severityText, body, attrs     string
+		svc, spanName                 string
+	)
+	err = db.QueryRow(`select
+		l.timestamp,
+		l.observed_timestamp,
+		l.flags,
+		l.severity_number,
+		l.severity_text,
+		l.body,
+		l.attributes,
+		l.__service_name,
+		s.name
+	from logs l join spans s on l.trace_id = s.trace_id and l.span_id = s.span_id;`).
+		Scan(&ts, &observed, &flags, &severity, &severityText, &body, &attrs, &svc, &spanName)
+	require.NoError(t, err)
+	assert.Equal(t, unixMicro(now.Add(-3*time.Millisecond)), ts)
+	assert.Equal(t, unixMicro(now), observed)
+	assert.Equal(t, int64(1), flags)
+	assert.Equal(t, int64(plog.SeverityNumberWarn), severity)
+	assert.Equal(t, "WARN", severityText)
+	assert.Equal(t, "cache miss", body)
+	assert.Equal(t, "{\"cache.key\":\"user:1\"}", attrs)
+	assert.Equal(t, "test-service", svc)
+	assert.Equal(t, "span1", spanName)
+
+	var traceID, spanID []byte
+	err = db.QueryRow(`select body, trace_id, span_id from logs where trace_id is null;`).Scan(&body, &traceID, &spanID)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"status\":200}", body)
+	assert.Nil(t, traceID)
+	assert.Nil(t, spanID)
+}
+
+func Test_SDKLogExporter(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	ex, err := NewSqliteSDKLogExporterWithDB(db)
+	require.NoError(t, err)
+
+	err = ex.Export(ctx, []sdklog.Record{
+		logtest.RecordFactory{
+			Timestamp: time.Unix(1000, 0),
+			Severity:  log.SeverityInfo,
+			Body:      log.StringValue("hello"),
+			TraceID:   trace.TraceID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+			SpanID:    trace.SpanID{0xee, 0xbc, 0x00, 0x00, 0x00, 0x00, 0xab, 0x01},
+		}.NewRecord(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ex.ForceFlush(ctx))
+
+	var ts, severity int64
+	var body string
+	var spanID []byte
+	err = db.QueryRow(`select timestamp, severity_number, body, span_id from logs;`).Scan(&ts, &severity, &body, &spanID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000_000_000), ts)
+	assert.Equal(t, int64(log.SeverityInfo), severity)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, []byte{0xee, 0xbc, 0x00, 0x00, 0x00, 0x00, 0xab, 0x01}, spanID)
+
+	require.NoError(t, ex.Shutdown(ctx))
+}
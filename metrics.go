@@ -0,0 +1,409 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// All the metrics tables share the same leading columns, the data point
+// specific columns come after those.
+const metricColumns string = `
+    __service_name,
+    metric_name,
+    metric_description,
+    metric_unit,
+    resource_attributes,
+    resource_dropped_attributes_count,
+    instrumentation_library_name,
+    instrumentation_library_version,
+    instrumentation_library_attributes,
+    attributes,
+    start_time,
+    time,
+    flags`
+
+const metricValues string = `?, ?, ?, ?, json(?), ?, ?, ?, json(?), json(?), ?, ?, ?`
+
+const insertGaugeQ string = `INSERT INTO metrics_gauge
+(` + metricColumns + `,
+    value_int,
+    value_double,
+    exemplars
+)
+VALUES (
+    ` + metricValues + `, ?, ?, json(?)
+);
+`
+
+const insertSumQ string = `INSERT INTO metrics_sum
+(` + metricColumns + `,
+    value_int,
+    value_double,
+    exemplars,
+    aggregation_temporality,
+    is_monotonic
+)
+VALUES (
+    ` + metricValues + `, ?, ?, json(?), ?, ?
+);
+`
+
+const insertHistogramQ string = `INSERT INTO metrics_histogram
+(` + metricColumns + `,
+    count,
+    sum,
+    min,
+    max,
+    bucket_counts,
+    explicit_bounds,
+    exemplars,
+    aggregation_temporality
+)
+VALUES (
+    ` + metricValues + `, ?, ?, ?, ?, json(?), json(?), json(?), ?
+);
+`
+
+const insertExponentialHistogramQ string = `INSERT INTO metrics_exponential_histogram
+(` + metricColumns + `,
+    count,
+    sum,
+    min,
+    max,
+    scale,
+    zero_count,
+    zero_threshold,
+    positive_offset,
+    positive_bucket_counts,
+    negative_offset,
+    negative_bucket_counts,
+    exemplars,
+    aggregation_temporality
+)
+VALUES (
+    ` + metricValues + `, ?, ?, ?, ?, ?, ?, ?, ?, json(?), ?, json(?), json(?), ?
+);
+`
+
+const insertSummaryQ string = `INSERT INTO metrics_summary
+(` + metricColumns + `,
+    count,
+    sum,
+    quantile_values
+)
+VALUES (
+    ` + metricValues + `, ?, ?, json(?)
+);
+`
+
+func (e *sqliteExporter) ConsumeMetrics(ctx context.Context, metrics pmetric.Metrics) error {
+	return e.write(ctx, metrics.DataPointCount(), func(ctx context.Context, tx *sql.Tx) error {
+		return e.insertMetrics(ctx, tx, metrics)
+	})
+}
+
+func (e *sqliteExporter) insertMetrics(ctx context.Context, tx *sql.Tx, metrics pmetric.Metrics) error {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		resource := metrics.ResourceMetrics().At(i)
+		svc := serviceName(resource.Resource())
+
+		rattrs, err := e.marshalAttributes(resource.Resource().Attributes())
+		if err != nil {
+			return fmt.Errorf("failed to marshal resource attributes as json: %w", err)
+		}
+
+		for j := 0; j < resource.ScopeMetrics().Len(); j++ {
+			scope := resource.ScopeMetrics().At(j)
+			sattrs, err := e.marshalAttributes(scope.Scope().Attributes())
+			if err != nil {
+				return fmt.Errorf("failed to marshal instrumentation scope attributes as json: %w", err)
+			}
+
+			for k := 0; k < scope.Metrics().Len(); k++ {
+				metric := scope.Metrics().At(k)
+
+				// columns shared by every data point of this metric, the data
+				// point attributes, timestamps and flags are appended below.
+				common := []any{
+					svc,
+					metric.Name(),
+					metric.Description(),
+					metric.Unit(),
+					rattrs,
+					resource.Resource().DroppedAttributesCount(),
+					scope.Scope().Name(),
+					scope.Scope().Version(),
+					sattrs,
+				}
+
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					err = e.insertGauge(ctx, tx, common, metric.Gauge())
+				case pmetric.MetricTypeSum:
+					err = e.insertSum(ctx, tx, common, metric.Sum())
+				case pmetric.MetricTypeHistogram:
+					err = e.insertHistogram(ctx, tx, common, metric.Histogram())
+				case pmetric.MetricTypeExponentialHistogram:
+					err = e.insertExponentialHistogram(ctx, tx, common, metric.ExponentialHistogram())
+				case pmetric.MetricTypeSummary:
+					err = e.insertSummary(ctx, tx, common, metric.Summary())
+				}
+				if err != nil {
+					return fmt.Errorf("error occured while inserting metric %q: %w", metric.Name(), err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *sqliteExporter) insertGauge(ctx context.Context, tx *sql.Tx, common []any, gauge pmetric.Gauge) error {
+	for i := 0; i < gauge.DataPoints().Len(); i++ {
+		dp := gauge.DataPoints().At(i)
+
+		args, err := e.dataPointArgs(common, dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(), dp.Flags())
+		if err != nil {
+			return err
+		}
+
+		exemplars, err := exemplarsAsJSON(dp.Exemplars())
+		if err != nil {
+			return fmt.Errorf("failed to marshal exemplars as json: %w", err)
+		}
+
+		vi, vd := numberValue(dp)
+		args = append(args, vi, vd, exemplars)
+		if _, err := tx.ExecContext(ctx, insertGaugeQ, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *sqliteExporter) insertSum(ctx context.Context, tx *sql.Tx, common []any, sum pmetric.Sum) error {
+	for i := 0; i < sum.DataPoints().Len(); i++ {
+		dp := sum.DataPoints().At(i)
+
+		args, err := e.dataPointArgs(common, dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(), dp.Flags())
+		if err != nil {
+			return err
+		}
+
+		exemplars, err := exemplarsAsJSON(dp.Exemplars())
+		if err != nil {
+			return fmt.Errorf("failed to marshal exemplars as json: %w", err)
+		}
+
+		vi, vd := numberValue(dp)
+		args = append(args, vi, vd, exemplars, sum.AggregationTemporality(), sum.IsMonotonic())
+		if _, err := tx.ExecContext(ctx, insertSumQ, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *sqliteExporter) insertHistogram(ctx context.Context, tx *sql.Tx, common []any, hist pmetric.Histogram) error {
+	for i := 0; i < hist.DataPoints().Len(); i++ {
+		dp := hist.DataPoints().At(i)
+
+		args, err := e.dataPointArgs(common, dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(), dp.Flags())
+		if err != nil {
+			return err
+		}
+
+		exemplars, err := exemplarsAsJSON(dp.Exemplars())
+		if err != nil {
+			return fmt.Errorf("failed to marshal exemplars as json: %w", err)
+		}
+
+		counts, err := json.Marshal(nonNil(dp.BucketCounts().AsRaw()))
+		if err != nil {
+			return fmt.Errorf("failed to marshal bucket counts as json: %w", err)
+		}
+
+		bounds, err := json.Marshal(nonNil(dp.ExplicitBounds().AsRaw()))
+		if err != nil {
+			return fmt.Errorf("failed to marshal explicit bounds as json: %w", err)
+		}
+
+		args = append(args,
+			dp.Count(),
+			optionalFloat(dp.Sum(), dp.HasSum()),
+			optionalFloat(dp.Min(), dp.HasMin()),
+			optionalFloat(dp.Max(), dp.HasMax()),
+			counts,
+			bounds,
+			exemplars,
+			hist.AggregationTemporality(),
+		)
+		if _, err := tx.ExecContext(ctx, insertHistogramQ, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *sqliteExporter) insertExponentialHistogram(ctx context.Context, tx *sql.Tx, common []any, hist pmetric.ExponentialHistogram) error {
+	for i := 0; i < hist.DataPoints().Len(); i++ {
+		dp := hist.DataPoints().At(i)
+
+		args, err := e.dataPointArgs(common, dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(), dp.Flags())
+		if err != nil {
+			return err
+		}
+
+		exemplars, err := exemplarsAsJSON(dp.Exemplars())
+		if err != nil {
+			return fmt.Errorf("failed to marshal exemplars as json: %w", err)
+		}
+
+		positive, err := json.Marshal(nonNil(dp.Positive().BucketCounts().AsRaw()))
+		if err != nil {
+			return fmt.Errorf("failed to marshal positive bucket counts as json: %w", err)
+		}
+
+		negative, err := json.Marshal(nonNil(dp.Negative().BucketCounts().AsRaw()))
+		if err != nil {
+			return fmt.Errorf("failed to marshal negative bucket counts as json: %w", err)
+		}
+
+		args = append(args,
+			dp.Count(),
+			optionalFloat(dp.Sum(), dp.HasSum()),
+			optionalFloat(dp.Min(), dp.HasMin()),
+			optionalFloat(dp.Max(), dp.HasMax()),
+			dp.Scale(),
+			dp.ZeroCount(),
+			dp.ZeroThreshold(),
+			dp.Positive().Offset(),
+			positive,
+			dp.Negative().Offset(),
+			negative,
+			exemplars,
+			hist.AggregationTemporality(),
+		)
+		if _, err := tx.ExecContext(ctx, insertExponentialHistogramQ, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type quantileValueJSON struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+func (e *sqliteExporter) insertSummary(ctx context.Context, tx *sql.Tx, common []any, summary pmetric.Summary) error {
+	for i := 0; i < summary.DataPoints().Len(); i++ {
+		dp := summary.DataPoints().At(i)
+
+		args, err := e.dataPointArgs(common, dp.Attributes(), dp.StartTimestamp(), dp.Timestamp(), dp.Flags())
+		if err != nil {
+			return err
+		}
+
+		qvs := make([]quantileValueJSON, 0, dp.QuantileValues().Len())
+		for j := 0; j < dp.QuantileValues().Len(); j++ {
+			qv := dp.QuantileValues().At(j)
+			qvs = append(qvs, quantileValueJSON{Quantile: qv.Quantile(), Value: qv.Value()})
+		}
+
+		quantiles, err := json.Marshal(qvs)
+		if err != nil {
+			return fmt.Errorf("failed to marshal quantile values as json: %w", err)
+		}
+
+		args = append(args, dp.Count(), dp.Sum(), quantiles)
+		if _, err := tx.ExecContext(ctx, insertSummaryQ, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *sqliteExporter) dataPointArgs(common []any, attributes pcommon.Map, start, ts pcommon.Timestamp, flags pmetric.DataPointFlags) ([]any, error) {
+	attrs, err := e.marshalAttributes(attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data point attributes as json: %w", err)
+	}
+
+	args := make([]any, 0, len(common)+16)
+	args = append(args, common...)
+	args = append(args,
+		attrs,
+		// Use microsecond precision for timestamps, same as spans
+		unixMicro(start.AsTime()),
+		unixMicro(ts.AsTime()),
+		uint32(flags),
+	)
+	return args, nil
+}
+
+// numberValue returns the value of the data point as either an int or a float,
+// the other one is always nil.
+func numberValue(dp pmetric.NumberDataPoint) (any, any) {
+	switch dp.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		return dp.IntValue(), nil
+	case pmetric.NumberDataPointValueTypeDouble:
+		return nil, dp.DoubleValue()
+	default:
+		return nil, nil
+	}
+}
+
+func optionalFloat(v float64, ok bool) any {
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// nonNil makes sure empty slices are marshalled as `[]` rather than `null`.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+type exemplarJSON struct {
+	Time               int64          `json:"time"`
+	Value              any            `json:"value"`
+	TraceID            string         `json:"trace_id,omitempty"`
+	SpanID             string         `json:"span_id,omitempty"`
+	FilteredAttributes map[string]any `json:"filtered_attributes,omitempty"`
+}
+
+func exemplarsAsJSON(exemplars pmetric.ExemplarSlice) ([]byte, error) {
+	out := make([]exemplarJSON, 0, exemplars.Len())
+	for i := 0; i < exemplars.Len(); i++ {
+		ex := exemplars.At(i)
+
+		var v any
+		switch ex.ValueType() {
+		case pmetric.ExemplarValueTypeInt:
+			v = ex.IntValue()
+		case pmetric.ExemplarValueTypeDouble:
+			v = ex.DoubleValue()
+		}
+
+		out = append(out, exemplarJSON{
+			Time:               unixMicro(ex.Timestamp().AsTime()),
+			Value:              v,
+			TraceID:            ex.TraceID().String(),
+			SpanID:             ex.SpanID().String(),
+			FilteredAttributes: ex.FilteredAttributes().AsRaw(),
+		})
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func Test_ExporterExportMetrics(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	// manually build the exporter so we can inspect the database
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	err = doMigrate(db)
+	require.NoError(t, err)
+
+	ex := sqliteExporter{db: db}
+
+	testMetrics := pmetric.NewMetrics()
+	rm := testMetrics.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "test-service")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("test-scope")
+	sm.Scope().SetVersion("v0.1.0")
+
+	gauge := sm.Metrics().AppendEmpty()
+	gauge.SetName("queue.depth")
+	gauge.SetUnit("{item}")
+	gdp := gauge.SetEmptyGauge().DataPoints().AppendEmpty()
+	gdp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	gdp.SetIntValue(42)
+	gdp.Attributes().PutStr("queue", "default")
+	gex := gdp.Exemplars().AppendEmpty()
+	gex.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	gex.SetIntValue(42)
+	gex.SetTraceID(pcommon.TraceID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	gex.SetSpanID(pcommon.SpanID{0xee, 0xbc, 0x00, 0x00, 0x00, 0x00, 0xab, 0x01})
+
+	sum := sm.Metrics().AppendEmpty()
+	sum.SetName("bytes.sent")
+	sum.SetUnit("By")
+	s := sum.SetEmptySum()
+	s.SetIsMonotonic(true)
+	s.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sdp := s.DataPoints().AppendEmpty()
+	sdp.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Minute)))
+	sdp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	sdp.SetDoubleValue(1024.5)
+
+	hist := sm.Metrics().AppendEmpty()
+	hist.SetName("http.server.duration")
+	hist.SetUnit("ms")
+	h := hist.SetEmptyHistogram()
+	h.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	hdp := h.DataPoints().AppendEmpty()
+	hdp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	hdp.SetCount(3)
+	hdp.SetSum(35)
+	hdp.SetMin(5)
+	hdp.ExplicitBounds().FromRaw([]float64{10, 100})
+	hdp.BucketCounts().FromRaw([]uint64{1, 2, 0})
+
+	ehist := sm.Metrics().AppendEmpty()
+	ehist.SetName("rpc.duration")
+	eh := ehist.SetEmptyExponentialHistogram()
+	ehdp := eh.DataPoints().AppendEmpty()
+	ehdp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	ehdp.SetCount(4)
+	ehdp.SetScale(2)
+	ehdp.SetZeroCount(1)
+	ehdp.Positive().SetOffset(3)
+	ehdp.Positive().BucketCounts().FromRaw([]uint64{1, 2})
+
+	summary := sm.Metrics().AppendEmpty()
+	summary.SetName("gc.pause")
+	sudp := summary.SetEmptySummary().DataPoints().AppendEmpty()
+	sudp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	sudp.SetCount(10)
+	sudp.SetSum(12.5)
+	q := sudp.QuantileValues().AppendEmpty()
+	q.SetQuantile(0.99)
+	q.SetValue(3.2)
+
+	err = ex.ConsumeMetrics(ctx, testMetrics)
+	require.NoError(t, err)
+
+	var (
+		svc, name, unit, attrs, exemplars string
+		ts                                int64
+		vi                                sql.NullInt64
+		vd                                sql.NullFloat64
+	)
+	err = db.QueryRow(`select __service_name, metric_name, metric_unit, attributes, time, value_int, value_double, exemplars from metrics_gauge;`).
+		Scan(&svc, &name, &unit, &attrs, &ts, &vi, &vd, &exemplars)
+	require.NoError(t, err)
+	assert.Equal(t, "test-service", svc)
+	assert.Equal(t, "queue.depth", name)
+	assert.Equal(t, "{item}", unit)
+	assert.Equal(t, "{\"queue\":\"default\"}", attrs)
+	assert.Equal(t, unixMicro(now), ts)
+	assert.Equal(t, sql.NullInt64{Int64: 42, Valid: true}, vi)
+	assert.False(t, vd.Valid)
+	assert.JSONEq(t, `[{"time":`+strconv.FormatInt(unixMicro(now), 10)+`,"value":42,"trace_id":"00000000000000000000000000000001","span_id":"eebc00000000ab01"}]`, exemplars)
+
+	var temporality int
+	var monotonic bool
+	err = db.QueryRow(`select value_int, value_double, aggregation_temporality, is_monotonic from metrics_sum;`).
+		Scan(&vi, &vd, &temporality, &monotonic)
+	require.NoError(t, err)
+	assert.False(t, vi.Valid)
+	assert.Equal(t, sql.NullFloat64{Float64: 1024.5, Valid: true}, vd)
+	assert.Equal(t, int(pmetric.AggregationTemporalityCumulative), temporality)
+	assert.True(t, monotonic)
+
+	var count int
+	var hsum, hmin, hmax sql.NullFloat64
+	var counts, bounds string
+	err = db.QueryRow(`select count, sum, min, max, bucket_counts, explicit_bounds from metrics_histogram;`).
+		Scan(&count, &hsum, &hmin, &hmax, &counts, &bounds)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, sql.NullFloat64{Float64: 35, Valid: true}, hsum)
+	assert.Equal(t, sql.NullFloat64{Float64: 5, Valid: true}, hmin)
+	assert.False(t, hmax.Valid)
+	assert.Equal(t, "[1,2,0]", counts)
+	assert.Equal(t, "[10,100]", bounds)
+
+	var scale, zeroCount, offset int
+	var positive, negative string
+	err = db.QueryRow(`select count, scale, zero_count, positive_offset, positive_bucket_counts, negative_bucket_counts from metrics_exponential_histogram;`).
+		Scan(&count, &scale, &zeroCount, &offset, &positive, &negative)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, 2, scale)
+	assert.Equal(t, 1, zeroCount)
+	assert.Equal(t, 3, offset)
+	assert.Equal(t, "[1,2]", positive)
+	assert.Equal(t, "[]", negative)
+
+	var quantiles string
+	var ssum float64
+	err = db.QueryRow(`select count, sum, quantile_values from metrics_summary;`).
+		Scan(&count, &ssum, &quantiles)
+	require.NoError(t, err)
+	assert.Equal(t, 10, count)
+	assert.Equal(t, 12.5, ssum)
+	assert.Equal(t, `[{"quantile":0.99,"value":3.2}]`, quantiles)
+}
@@ -0,0 +1,6 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+DROP TABLE metrics_summary;
+DROP TABLE metrics_exponential_histogram;
+DROP TABLE metrics_histogram;
+DROP TABLE metrics_sum;
+DROP TABLE metrics_gauge;
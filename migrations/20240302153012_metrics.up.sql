@@ -0,0 +1,122 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+CREATE TABLE IF NOT EXISTS metrics_gauge(
+    "__service_name" TEXT,
+    "metric_name" TEXT,
+    "metric_description" TEXT,
+    "metric_unit" TEXT,
+    "resource_attributes" TEXT,
+    "resource_dropped_attributes_count" INTEGER,
+    "instrumentation_library_name" TEXT,
+    "instrumentation_library_version" TEXT,
+    "instrumentation_library_attributes" TEXT,
+    "attributes" TEXT,
+    "start_time" INTEGER, -- start_time is a microsecond precision unix timestamp
+    "time" INTEGER, -- time is a microsecond precision unix timestamp
+    "flags" INTEGER,
+    "value_int" INTEGER, -- only one of value_int and value_double is set
+    "value_double" REAL,
+    "exemplars" TEXT
+);
+
+CREATE INDEX IF NOT EXISTS metrics_gauge_name_time_idx ON metrics_gauge("metric_name", "time");
+
+CREATE TABLE IF NOT EXISTS metrics_sum(
+    "__service_name" TEXT,
+    "metric_name" TEXT,
+    "metric_description" TEXT,
+    "metric_unit" TEXT,
+    "resource_attributes" TEXT,
+    "resource_dropped_attributes_count" INTEGER,
+    "instrumentation_library_name" TEXT,
+    "instrumentation_library_version" TEXT,
+    "instrumentation_library_attributes" TEXT,
+    "attributes" TEXT,
+    "start_time" INTEGER, -- start_time is a microsecond precision unix timestamp
+    "time" INTEGER, -- time is a microsecond precision unix timestamp
+    "flags" INTEGER,
+    "value_int" INTEGER, -- only one of value_int and value_double is set
+    "value_double" REAL,
+    "exemplars" TEXT,
+    "aggregation_temporality" INTEGER,
+    "is_monotonic" INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS metrics_sum_name_time_idx ON metrics_sum("metric_name", "time");
+
+CREATE TABLE IF NOT EXISTS metrics_histogram(
+    "__service_name" TEXT,
+    "metric_name" TEXT,
+    "metric_description" TEXT,
+    "metric_unit" TEXT,
+    "resource_attributes" TEXT,
+    "resource_dropped_attributes_count" INTEGER,
+    "instrumentation_library_name" TEXT,
+    "instrumentation_library_version" TEXT,
+    "instrumentation_library_attributes" TEXT,
+    "attributes" TEXT,
+    "start_time" INTEGER, -- start_time is a microsecond precision unix timestamp
+    "time" INTEGER, -- time is a microsecond precision unix timestamp
+    "flags" INTEGER,
+    "count" INTEGER,
+    "sum" REAL, -- sum, min and max are NULL when not set on the data point
+    "min" REAL,
+    "max" REAL,
+    "bucket_counts" TEXT, -- JSON array of integers
+    "explicit_bounds" TEXT, -- JSON array of floats
+    "exemplars" TEXT,
+    "aggregation_temporality" INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS metrics_histogram_name_time_idx ON metrics_histogram("metric_name", "time");
+
+CREATE TABLE IF NOT EXISTS metrics_exponential_histogram(
+    "__service_name" TEXT,
+    "metric_name" TEXT,
+    "metric_description" TEXT,
+    "metric_unit" TEXT,
+    "resource_attributes" TEXT,
+    "resource_dropped_attributes_count" INTEGER,
+    "instrumentation_library_name" TEXT,
+    "instrumentation_library_version" TEXT,
+    "instrumentation_library_attributes" TEXT,
+    "attributes" TEXT,
+    "start_time" INTEGER, -- start_time is a microsecond precision unix timestamp
+    "time" INTEGER, -- time is a microsecond precision unix timestamp
+    "flags" INTEGER,
+    "count" INTEGER,
+    "sum" REAL, -- sum, min and max are NULL when not set on the data point
+    "min" REAL,
+    "max" REAL,
+    "scale" INTEGER,
+    "zero_count" INTEGER,
+    "zero_threshold" REAL,
+    "positive_offset" INTEGER,
+    "positive_bucket_counts" TEXT, -- JSON array of integers
+    "negative_offset" INTEGER,
+    "negative_bucket_counts" TEXT, -- JSON array of integers
+    "exemplars" TEXT,
+    "aggregation_temporality" INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS metrics_exponential_histogram_name_time_idx ON metrics_exponential_histogram("metric_name", "time");
+
+CREATE TABLE IF NOT EXISTS metrics_summary(
+    "__service_name" TEXT,
+    "metric_name" TEXT,
+    "metric_description" TEXT,
+    "metric_unit" TEXT,
+    "resource_attributes" TEXT,
+    "resource_dropped_attributes_count" INTEGER,
+    "instrumentation_library_name" TEXT,
+    "instrumentation_library_version" TEXT,
+    "instrumentation_library_attributes" TEXT,
+    "attributes" TEXT,
+    "start_time" INTEGER, -- start_time is a microsecond precision unix timestamp
+    "time" INTEGER, -- time is a microsecond precision unix timestamp
+    "flags" INTEGER,
+    "count" INTEGER,
+    "sum" REAL,
+    "quantile_values" TEXT -- JSON array of {"quantile": float, "value": float} objects
+);
+
+CREATE INDEX IF NOT EXISTS metrics_summary_name_time_idx ON metrics_summary("metric_name", "time");
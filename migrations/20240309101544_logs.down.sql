@@ -0,0 +1,2 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+DROP TABLE logs;
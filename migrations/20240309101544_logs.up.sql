@@ -0,0 +1,22 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+CREATE TABLE IF NOT EXISTS logs(
+    "timestamp" INTEGER, -- timestamp is a microsecond precision unix timestamp
+    "observed_timestamp" INTEGER, -- observed_timestamp is a microsecond precision unix timestamp
+    "trace_id" BLOB,
+    "span_id" BLOB,
+    "flags" INTEGER,
+    "severity_number" INTEGER,
+    "severity_text" TEXT,
+    "body" TEXT, -- string bodies are stored as-is, any other type is JSON-encoded
+    "attributes" TEXT,
+    "dropped_attributes_count" INTEGER,
+    "__service_name" TEXT,
+    "resource_attributes" TEXT,
+    "resource_dropped_attributes_count" INTEGER,
+    "instrumentation_library_name" TEXT,
+    "instrumentation_library_version" TEXT,
+    "instrumentation_library_attributes" TEXT
+);
+
+CREATE INDEX IF NOT EXISTS logs_trace_id_span_id_idx ON logs("trace_id", "span_id");
+CREATE INDEX IF NOT EXISTS logs_service_name_timestamp_idx ON logs("__service_name", "timestamp");
@@ -0,0 +1,6 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+DROP INDEX links_parent_trace_id_parent_span_id_link_index_idx;
+DROP INDEX events_span_id_event_index_idx;
+ALTER TABLE links DROP COLUMN parent_trace_id;
+ALTER TABLE links DROP COLUMN link_index;
+ALTER TABLE events DROP COLUMN event_index;
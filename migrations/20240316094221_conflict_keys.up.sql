@@ -0,0 +1,16 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Events and links are keyed by their position within their span so that
+-- sending the same span twice doesn't duplicate them. Span IDs are only unique
+-- within a trace, so links are keyed by the trace ID of their span as well,
+-- backfilled from the spans table on a best effort basis. Rows written before
+-- this migration have a NULL index and are never considered duplicates.
+ALTER TABLE events ADD COLUMN event_index INTEGER DEFAULT NULL;
+ALTER TABLE links ADD COLUMN link_index INTEGER DEFAULT NULL;
+ALTER TABLE links ADD COLUMN parent_trace_id BLOB DEFAULT NULL;
+
+UPDATE links SET parent_trace_id = (
+    SELECT spans.trace_id FROM spans WHERE spans.span_id = links.parent_span_id LIMIT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_span_id_event_index_idx ON events("span_id", "event_index");
+CREATE UNIQUE INDEX IF NOT EXISTS links_parent_trace_id_parent_span_id_link_index_idx ON links("parent_trace_id", "parent_span_id", "link_index");
@@ -0,0 +1,7 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Columns of the spans table added for hoisted attributes, so they can be told
+-- apart from the columns created by the migrations.
+CREATE TABLE IF NOT EXISTS hoisted_columns(
+    "column_name" TEXT PRIMARY KEY,
+    "column_type" TEXT
+);
@@ -0,0 +1,7 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Settings the exporter was last started with, used to convert existing rows
+-- when a setting affecting the storage format changes.
+CREATE TABLE IF NOT EXISTS exporter_settings(
+    "key" TEXT PRIMARY KEY,
+    "value" TEXT
+);
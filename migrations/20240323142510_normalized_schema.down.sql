@@ -0,0 +1,6 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+DROP VIEW IF EXISTS spans_denormalized;
+ALTER TABLE spans DROP COLUMN scope_id;
+ALTER TABLE spans DROP COLUMN resource_id;
+DROP TABLE scopes;
+DROP TABLE resources;
@@ -0,0 +1,20 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Resources and instrumentation scopes are stored once, keyed by a hash of
+-- their content, when the exporter uses the normalized schema. The inlined
+-- resource and scope columns of the spans table are left NULL in that case.
+CREATE TABLE IF NOT EXISTS resources(
+    "resource_id" INTEGER PRIMARY KEY, -- hash of the resource content
+    "__service_name" TEXT,
+    "attributes" TEXT,
+    "dropped_attributes_count" INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS scopes(
+    "scope_id" INTEGER PRIMARY KEY, -- hash of the scope content
+    "name" TEXT,
+    "version" TEXT,
+    "attributes" TEXT
+);
+
+ALTER TABLE spans ADD COLUMN resource_id INTEGER DEFAULT NULL REFERENCES resources("resource_id");
+ALTER TABLE spans ADD COLUMN scope_id INTEGER DEFAULT NULL REFERENCES scopes("scope_id");
@@ -0,0 +1,40 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+DROP INDEX spans_service_name_start_time_idx;
+DROP INDEX spans_start_time_idx;
+DROP INDEX spans_trace_id_idx;
+
+CREATE TABLE events_old(
+    "span_id" BLOB,
+    "timestamp" INTEGER, -- timestamp is a microsecond precision unix timestamp
+    "name" TEXT,
+    "attributes" TEXT,
+    "dropped_attributes_count" INTEGER,
+    "event_index" INTEGER DEFAULT NULL,
+    FOREIGN KEY ("span_id") REFERENCES spans("span_id")
+);
+
+INSERT INTO events_old (span_id, timestamp, name, attributes, dropped_attributes_count, event_index)
+SELECT span_id, timestamp, name, attributes, dropped_attributes_count, event_index FROM events;
+
+DROP TABLE events;
+ALTER TABLE events_old RENAME TO events;
+
+CREATE TABLE links_old(
+    "parent_span_id" BLOB,
+    "span_id" BLOB,
+    "trace_id" BLOB,
+    "tracestate" TEXT,
+    "attributes" TEXT,
+    "dropped_attributes_count" INTEGER,
+    "link_index" INTEGER DEFAULT NULL,
+    "parent_trace_id" BLOB DEFAULT NULL
+);
+
+INSERT INTO links_old (parent_span_id, span_id, trace_id, tracestate, attributes, dropped_attributes_count, link_index, parent_trace_id)
+SELECT parent_span_id, span_id, trace_id, tracestate, attributes, dropped_attributes_count, link_index, parent_trace_id FROM links;
+
+DROP TABLE links;
+ALTER TABLE links_old RENAME TO links;
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_span_id_event_index_idx ON events("span_id", "event_index");
+CREATE UNIQUE INDEX IF NOT EXISTS links_parent_trace_id_parent_span_id_link_index_idx ON links("parent_trace_id", "parent_span_id", "link_index");
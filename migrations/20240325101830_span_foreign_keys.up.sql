@@ -0,0 +1,56 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Events and links reference their span with the complete primary key of the
+-- spans table. Sqlite can't alter the constraints of an existing table, so
+-- both tables are rebuilt. Events get the trace ID of their span, backfilled
+-- from the spans table on a best effort basis, and are keyed by it.
+CREATE TABLE events_new(
+    "span_id" BLOB,
+    "trace_id" BLOB,
+    "timestamp" INTEGER, -- timestamp is a microsecond precision unix timestamp
+    "name" TEXT,
+    "attributes" TEXT,
+    "dropped_attributes_count" INTEGER,
+    "event_index" INTEGER DEFAULT NULL,
+    FOREIGN KEY ("span_id", "trace_id") REFERENCES spans("span_id", "trace_id")
+);
+
+INSERT INTO events_new (span_id, trace_id, timestamp, name, attributes, dropped_attributes_count, event_index)
+SELECT
+    span_id,
+    (SELECT spans.trace_id FROM spans WHERE spans.span_id = events.span_id LIMIT 1),
+    timestamp,
+    name,
+    attributes,
+    dropped_attributes_count,
+    event_index
+FROM events;
+
+DROP TABLE events;
+ALTER TABLE events_new RENAME TO events;
+
+CREATE TABLE links_new(
+    "parent_span_id" BLOB,
+    "parent_trace_id" BLOB,
+    "span_id" BLOB,
+    "trace_id" BLOB,
+    "tracestate" TEXT,
+    "attributes" TEXT,
+    "dropped_attributes_count" INTEGER,
+    "link_index" INTEGER DEFAULT NULL,
+    FOREIGN KEY ("parent_span_id", "parent_trace_id") REFERENCES spans("span_id", "trace_id")
+);
+
+INSERT INTO links_new (parent_span_id, parent_trace_id, span_id, trace_id, tracestate, attributes, dropped_attributes_count, link_index)
+SELECT parent_span_id, parent_trace_id, span_id, trace_id, tracestate, attributes, dropped_attributes_count, link_index FROM links;
+
+DROP TABLE links;
+ALTER TABLE links_new RENAME TO links;
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_trace_id_span_id_event_index_idx ON events("trace_id", "span_id", "event_index");
+CREATE UNIQUE INDEX IF NOT EXISTS links_parent_trace_id_parent_span_id_link_index_idx ON links("parent_trace_id", "parent_span_id", "link_index");
+CREATE INDEX IF NOT EXISTS events_span_id_idx ON events("span_id");
+CREATE INDEX IF NOT EXISTS links_parent_span_id_idx ON links("parent_span_id");
+
+CREATE INDEX IF NOT EXISTS spans_trace_id_idx ON spans("trace_id");
+CREATE INDEX IF NOT EXISTS spans_start_time_idx ON spans("start_time");
+CREATE INDEX IF NOT EXISTS spans_service_name_start_time_idx ON spans("__service_name", "start_time");
@@ -0,0 +1,11 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- the view reads the dropped columns, it is recreated by the exporter on
+-- startup.
+DROP VIEW IF EXISTS spans_denormalized;
+ALTER TABLE scopes DROP COLUMN dropped_attributes_count;
+ALTER TABLE scopes DROP COLUMN schema_url;
+ALTER TABLE resources DROP COLUMN schema_url;
+
+ALTER TABLE spans DROP COLUMN instrumentation_library_dropped_attributes_count;
+ALTER TABLE spans DROP COLUMN instrumentation_library_schema_url;
+ALTER TABLE spans DROP COLUMN resource_schema_url;
@@ -0,0 +1,10 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- The schema URLs of resources and scopes, and the dropped attributes count of
+-- scopes, are stored so that spans can be read back without losing any field.
+ALTER TABLE spans ADD COLUMN resource_schema_url TEXT DEFAULT NULL;
+ALTER TABLE spans ADD COLUMN instrumentation_library_schema_url TEXT DEFAULT NULL;
+ALTER TABLE spans ADD COLUMN instrumentation_library_dropped_attributes_count INTEGER DEFAULT NULL;
+
+ALTER TABLE resources ADD COLUMN schema_url TEXT DEFAULT NULL;
+ALTER TABLE scopes ADD COLUMN schema_url TEXT DEFAULT NULL;
+ALTER TABLE scopes ADD COLUMN dropped_attributes_count INTEGER DEFAULT NULL;
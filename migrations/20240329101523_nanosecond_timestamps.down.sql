@@ -0,0 +1,11 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- the view reads the dropped columns, it is recreated by the exporter on
+-- startup.
+DROP VIEW IF EXISTS spans_denormalized;
+DROP VIEW IF EXISTS events_ns;
+
+ALTER TABLE events DROP COLUMN timestamp_ns;
+
+ALTER TABLE spans DROP COLUMN __duration_ns;
+ALTER TABLE spans DROP COLUMN end_time_ns;
+ALTER TABLE spans DROP COLUMN start_time_ns;
@@ -0,0 +1,23 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Nanosecond timestamps are written alongside the microsecond ones when the
+-- exporter is configured with `timestamp_precision: ns`, and are NULL
+-- otherwise. The microsecond columns are always written so existing queries
+-- keep working.
+ALTER TABLE spans ADD COLUMN start_time_ns INTEGER DEFAULT NULL; -- nanosecond precision unix timestamp
+ALTER TABLE spans ADD COLUMN end_time_ns INTEGER DEFAULT NULL; -- nanosecond precision unix timestamp
+ALTER TABLE spans ADD COLUMN __duration_ns INTEGER DEFAULT NULL;
+
+ALTER TABLE events ADD COLUMN timestamp_ns INTEGER DEFAULT NULL; -- nanosecond precision unix timestamp
+
+-- events_ns exposes the events with both timestamps, the nanosecond one
+-- falling back to the microsecond one for events written without it.
+CREATE VIEW IF NOT EXISTS events_ns AS SELECT
+    span_id,
+    trace_id,
+    timestamp,
+    coalesce(timestamp_ns, timestamp * 1000) AS timestamp_ns,
+    name,
+    attributes,
+    dropped_attributes_count,
+    event_index
+FROM events;
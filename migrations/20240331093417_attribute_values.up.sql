@@ -0,0 +1,61 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- span_attribute_values has a row for every attribute of every span, with the
+-- type of the value and the value itself, whichever attribute_encoding the
+-- span was written with. With otlp_json, values are read from their AnyValue
+-- object, e.g. {"intValue":"200"} is the int 200. A value written with the
+-- json encoding is only mistaken for an AnyValue if it is a map with a single
+-- key named like an AnyValue field, e.g. {"stringValue":"a"}.
+--
+-- type is one of string, bool, int, double, bytes, array, kvlist or empty.
+-- Bools are 0 or 1, bytes are base64 encoded and arrays and kvlists are JSON
+-- in the encoding they were written with.
+CREATE VIEW IF NOT EXISTS span_attribute_values AS SELECT
+    trace_id,
+    span_id,
+    key,
+    coalesce(otlp_type, CASE json_type
+        WHEN 'text' THEN 'string'
+        WHEN 'integer' THEN 'int'
+        WHEN 'real' THEN 'double'
+        WHEN 'true' THEN 'bool'
+        WHEN 'false' THEN 'bool'
+        WHEN 'array' THEN 'array'
+        WHEN 'object' THEN 'kvlist'
+        ELSE 'empty'
+    END) AS type,
+    CASE otlp_type
+        WHEN 'string' THEN json_extract(value, '$.stringValue')
+        WHEN 'bool' THEN json_extract(value, '$.boolValue')
+        WHEN 'int' THEN CAST(json_extract(value, '$.intValue') AS INTEGER)
+        WHEN 'double' THEN json_extract(value, '$.doubleValue')
+        WHEN 'bytes' THEN json_extract(value, '$.bytesValue')
+        WHEN 'array' THEN json_extract(value, '$.arrayValue.values')
+        WHEN 'kvlist' THEN json_extract(value, '$.kvlistValue.values')
+        WHEN 'empty' THEN NULL
+        ELSE value
+    END AS value
+FROM (
+    SELECT
+        spans.trace_id,
+        spans.span_id,
+        attr.key,
+        attr.type AS json_type,
+        attr.value,
+        -- the AnyValue field set in an object with at most one key, NULL for
+        -- any other value.
+        CASE WHEN attr.type = 'object' THEN (
+            SELECT CASE
+                WHEN count(*) = 0 THEN 'empty'
+                WHEN max(field.key) = 'stringValue' THEN 'string'
+                WHEN max(field.key) = 'boolValue' THEN 'bool'
+                WHEN max(field.key) = 'intValue' THEN 'int'
+                WHEN max(field.key) = 'doubleValue' THEN 'double'
+                WHEN max(field.key) = 'bytesValue' THEN 'bytes'
+                WHEN max(field.key) = 'arrayValue' THEN 'array'
+                WHEN max(field.key) = 'kvlistValue' THEN 'kvlist'
+            END
+            FROM json_each(attr.value) AS field
+            HAVING count(*) <= 1
+        ) END AS otlp_type
+    FROM spans, json_each(spans.attributes) AS attr
+);
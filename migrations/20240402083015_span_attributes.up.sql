@@ -0,0 +1,26 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- span_attributes has a row for every indexed span attribute, written when the
+-- exporter is configured with `attribute_index.enabled`. Only one of the value
+-- columns is set, depending on the type of the attribute, one of string, int,
+-- double or bool. Each value column is indexed along with the key, so a span
+-- can be found by the value of one of its attributes without scanning the
+-- attributes JSON of every span.
+CREATE TABLE IF NOT EXISTS span_attributes(
+    "trace_id" BLOB NOT NULL,
+    "span_id" BLOB NOT NULL,
+    "key" TEXT NOT NULL,
+    "type" TEXT NOT NULL,
+    "str_value" TEXT DEFAULT NULL,
+    "int_value" INTEGER DEFAULT NULL,
+    "double_value" REAL DEFAULT NULL,
+    "bool_value" INTEGER DEFAULT NULL,
+    PRIMARY KEY ("trace_id", "span_id", "key"),
+    FOREIGN KEY ("span_id", "trace_id") REFERENCES spans("span_id", "trace_id")
+) WITHOUT ROWID;
+
+-- the indexes only hold the rows with a value of their type, and are used by
+-- any query comparing the value column, e.g. key = ? AND str_value = ?.
+CREATE INDEX IF NOT EXISTS span_attributes_key_str_value_idx ON span_attributes("key", "str_value") WHERE "str_value" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS span_attributes_key_int_value_idx ON span_attributes("key", "int_value") WHERE "int_value" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS span_attributes_key_double_value_idx ON span_attributes("key", "double_value") WHERE "double_value" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS span_attributes_key_bool_value_idx ON span_attributes("key", "bool_value") WHERE "bool_value" IS NOT NULL;
@@ -0,0 +1,37 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- traces has a summary of every trace, recomputed from the spans table by the
+-- exporter for every trace a batch has spans in, and when retention deletes
+-- spans. Summaries are always computed from every stored span of the trace,
+-- so they don't depend on the order the spans arrive in.
+CREATE TABLE IF NOT EXISTS traces(
+    "trace_id" BLOB PRIMARY KEY,
+    -- service and name of the root span, the span without a parent starting
+    -- first, NULL until it is stored.
+    "root_service_name" TEXT DEFAULT NULL,
+    "root_span_name" TEXT DEFAULT NULL,
+    "start_time" INTEGER, -- earliest start time of the spans, microsecond precision unix timestamp
+    "end_time" INTEGER, -- latest end time of the spans, microsecond precision unix timestamp
+    "start_time_ns" INTEGER, -- nanosecond precision, when the spans have it
+    "end_time_ns" INTEGER, -- nanosecond precision, when the spans have it
+    "span_count" INTEGER NOT NULL,
+    "error_count" INTEGER NOT NULL,
+    "services" TEXT NOT NULL -- sorted JSON array of the service names of the spans
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS traces_start_time_ns_idx ON traces("start_time_ns");
+
+-- the statement is the same as the one run by the exporter, for every trace.
+INSERT OR REPLACE INTO traces (trace_id, root_service_name, root_span_name, start_time, end_time, start_time_ns, end_time_ns, span_count, error_count, services)
+SELECT
+    spans.trace_id,
+    (SELECT root.__service_name FROM spans AS root WHERE root.trace_id = spans.trace_id AND root.parent_span_id IS NULL ORDER BY root.start_time, root.span_id LIMIT 1),
+    (SELECT root.name FROM spans AS root WHERE root.trace_id = spans.trace_id AND root.parent_span_id IS NULL ORDER BY root.start_time, root.span_id LIMIT 1),
+    min(spans.start_time),
+    max(spans.end_time),
+    min(coalesce(spans.start_time_ns, spans.start_time * 1000)),
+    max(coalesce(spans.end_time_ns, spans.end_time * 1000)),
+    count(*),
+    count(*) FILTER (WHERE spans.status_code = 2),
+    (SELECT json_group_array(name) FROM (SELECT DISTINCT s.__service_name AS name FROM spans AS s WHERE s.trace_id = spans.trace_id ORDER BY name))
+FROM spans
+GROUP BY spans.trace_id;
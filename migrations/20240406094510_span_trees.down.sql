@@ -0,0 +1,12 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- the view reads the dropped columns, it is recreated by the exporter on
+-- startup.
+DROP VIEW IF EXISTS spans_denormalized;
+
+DROP TABLE IF EXISTS span_ancestry;
+
+DROP INDEX IF EXISTS spans_trace_id_parent_span_id_idx;
+
+ALTER TABLE spans DROP COLUMN child_count;
+ALTER TABLE spans DROP COLUMN depth;
+ALTER TABLE spans DROP COLUMN root_span_id;
@@ -0,0 +1,54 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- Span tree metadata, maintained by the exporter for every trace a batch has
+-- spans in, from all the stored spans of the trace, so it is backfilled when a
+-- parent arrives after its children. root_span_id and depth are NULL until
+-- the span is connected to a root span, a span without a parent, through
+-- stored spans. depth is 0 for the root itself.
+ALTER TABLE spans ADD COLUMN root_span_id BLOB DEFAULT NULL;
+ALTER TABLE spans ADD COLUMN depth INTEGER DEFAULT NULL;
+ALTER TABLE spans ADD COLUMN child_count INTEGER DEFAULT NULL; -- number of stored spans with this span as their parent
+
+CREATE INDEX IF NOT EXISTS spans_trace_id_parent_span_id_idx ON spans("trace_id", "parent_span_id");
+
+-- span_ancestry is the closure of the parent relationship of the stored spans:
+-- a row for every span and each of its stored ancestors, including itself at
+-- distance 0. A chain of ancestors stops at the first parent that isn't
+-- stored, or after 1000 levels.
+CREATE TABLE IF NOT EXISTS span_ancestry(
+    "trace_id" BLOB NOT NULL,
+    "ancestor_span_id" BLOB NOT NULL,
+    "descendant_span_id" BLOB NOT NULL,
+    "distance" INTEGER NOT NULL,
+    PRIMARY KEY ("trace_id", "ancestor_span_id", "descendant_span_id")
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS span_ancestry_trace_id_descendant_span_id_idx ON span_ancestry("trace_id", "descendant_span_id", "distance");
+
+-- the statements are the same as the ones run by the exporter, for every
+-- trace.
+WITH RECURSIVE ancestry(trace_id, ancestor_span_id, descendant_span_id, distance) AS (
+    SELECT trace_id, span_id, span_id, 0 FROM spans
+    UNION ALL
+    SELECT ancestry.trace_id, parent.span_id, ancestry.descendant_span_id, ancestry.distance + 1
+    FROM ancestry
+    JOIN spans AS child ON child.trace_id = ancestry.trace_id AND child.span_id = ancestry.ancestor_span_id
+    JOIN spans AS parent ON parent.trace_id = child.trace_id AND parent.span_id = child.parent_span_id
+    WHERE ancestry.distance < 1000
+)
+INSERT OR IGNORE INTO span_ancestry (trace_id, ancestor_span_id, descendant_span_id, distance)
+SELECT trace_id, ancestor_span_id, descendant_span_id, distance FROM ancestry;
+
+UPDATE spans SET
+    root_span_id = (
+        SELECT root.ancestor_span_id FROM span_ancestry AS root
+        JOIN spans AS r ON r.trace_id = root.trace_id AND r.span_id = root.ancestor_span_id
+        WHERE root.trace_id = spans.trace_id AND root.descendant_span_id = spans.span_id AND r.parent_span_id IS NULL
+    ),
+    depth = (
+        SELECT root.distance FROM span_ancestry AS root
+        JOIN spans AS r ON r.trace_id = root.trace_id AND r.span_id = root.ancestor_span_id
+        WHERE root.trace_id = spans.trace_id AND root.descendant_span_id = spans.span_id AND r.parent_span_id IS NULL
+    ),
+    child_count = (
+        SELECT count(*) FROM spans AS c WHERE c.trace_id = spans.trace_id AND c.parent_span_id = spans.span_id
+    );
@@ -0,0 +1,8 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- the view reads the dropped column, it is recreated by the exporter on
+-- startup.
+DROP VIEW IF EXISTS spans_denormalized;
+
+DROP TABLE IF EXISTS critical_path;
+
+ALTER TABLE spans DROP COLUMN self_time_ns;
@@ -0,0 +1,59 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- self_time_ns is the duration of the span minus the union of the intervals
+-- of its stored children, clipped to the span, in nanoseconds. It is
+-- maintained by the exporter for every trace a batch has spans in, like the
+-- span trees.
+ALTER TABLE spans ADD COLUMN self_time_ns INTEGER DEFAULT NULL;
+
+-- critical_path has the segments of the critical path of every trace with a
+-- root span, in order. The segments are contiguous and cover the root span,
+-- each one is time spent in span_id outside of any of its children on the
+-- path. Traces written before this migration don't have a critical path
+-- until one of their spans is written again.
+CREATE TABLE IF NOT EXISTS critical_path(
+    "trace_id" BLOB NOT NULL,
+    "position" INTEGER NOT NULL,
+    "span_id" BLOB NOT NULL,
+    "start_time_ns" INTEGER NOT NULL,
+    "end_time_ns" INTEGER NOT NULL,
+    PRIMARY KEY ("trace_id", "position")
+) WITHOUT ROWID;
+
+-- the statement is the same as the one run by the exporter, for every trace.
+WITH bounds AS (
+    SELECT
+        trace_id,
+        span_id,
+        parent_span_id,
+        coalesce(start_time_ns, start_time * 1000) AS start_ns,
+        coalesce(end_time_ns, end_time * 1000) AS end_ns
+    FROM spans
+), children AS (
+    SELECT
+        parent.trace_id,
+        parent.span_id,
+        max(child.start_ns, parent.start_ns) AS start_ns,
+        min(child.end_ns, parent.end_ns) AS end_ns
+    FROM bounds AS parent
+    JOIN bounds AS child ON child.trace_id = parent.trace_id AND child.parent_span_id = parent.span_id AND child.span_id != parent.span_id
+    WHERE max(child.start_ns, parent.start_ns) < min(child.end_ns, parent.end_ns)
+), covered AS (
+    -- the length of the union of the intervals, sorted by start, is the sum
+    -- of the part of each interval past the end of all the previous ones.
+    SELECT trace_id, span_id, sum(max(0, end_ns - max(start_ns, coalesce(prev_end_ns, start_ns)))) AS covered_ns
+    FROM (
+        SELECT *, max(end_ns) OVER (
+            PARTITION BY trace_id, span_id ORDER BY start_ns, end_ns
+            ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+        ) AS prev_end_ns
+        FROM children
+    )
+    GROUP BY trace_id, span_id
+)
+UPDATE spans SET self_time_ns = self.self_time_ns
+FROM (
+    SELECT bounds.trace_id, bounds.span_id, max(0, bounds.end_ns - bounds.start_ns) - coalesce(covered.covered_ns, 0) AS self_time_ns
+    FROM bounds
+    LEFT JOIN covered ON covered.trace_id = bounds.trace_id AND covered.span_id = bounds.span_id
+) AS self
+WHERE spans.trace_id = self.trace_id AND spans.span_id = self.span_id;
@@ -0,0 +1,39 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- span_rollups has the aggregates of the spans of every service, span name,
+-- kind and status code by time bucket, maintained by the exporter when
+-- rollups are enabled. time_bucket is the start of the bucket and
+-- bucket_width its width, both in microseconds, spans are bucketed by start
+-- time. duration_sum is in nanoseconds. Rollups aren't pruned with the spans.
+--
+-- The duration histogram is either explicit, with the upper bounds of the
+-- buckets in nanoseconds in explicit_bounds and one more count than bounds in
+-- bucket_counts, or exponential, with the counts of the consecutive buckets
+-- starting at index bucket_offset of the given scale in bucket_counts, and
+-- the spans with a duration of 0 in zero_count.
+CREATE TABLE IF NOT EXISTS span_rollups(
+    "__service_name" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "kind" TEXT NOT NULL,
+    "status_code" INTEGER NOT NULL,
+    "bucket_width" INTEGER NOT NULL,
+    "time_bucket" INTEGER NOT NULL,
+    "request_count" INTEGER NOT NULL,
+    "error_count" INTEGER NOT NULL,
+    "duration_sum" INTEGER NOT NULL,
+    "histogram_type" TEXT NOT NULL,
+    "explicit_bounds" TEXT DEFAULT NULL,
+    "scale" INTEGER DEFAULT NULL,
+    "zero_count" INTEGER DEFAULT NULL,
+    "bucket_offset" INTEGER DEFAULT NULL,
+    "bucket_counts" TEXT NOT NULL,
+    PRIMARY KEY ("__service_name", "name", "kind", "status_code", "bucket_width", "time_bucket")
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS span_rollups_time_bucket_idx ON span_rollups("time_bucket");
+
+-- rollup_pending is set on the spans written while rollups are enabled, and
+-- cleared once they are added to their rollup in the same transaction, so a
+-- span is only rolled up once even when it is sent again.
+ALTER TABLE spans ADD COLUMN rollup_pending INTEGER DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS spans_rollup_pending_idx ON spans("rollup_pending") WHERE "rollup_pending" IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- the view reads the dropped column, it is recreated by the exporter on
+-- startup.
+DROP VIEW IF EXISTS spans_denormalized;
+
+DROP INDEX IF EXISTS spans_service_edge_pending_idx;
+
+ALTER TABLE spans DROP COLUMN service_edge_pending;
+
+DROP TABLE IF EXISTS service_edges;
@@ -0,0 +1,28 @@
+-- Copyright 2024 William Perron. All rights reserved. MIT License.
+-- service_edges has the calls between services, from the spans whose parent
+-- span is stored and belongs to another service, maintained by the exporter
+-- when the service graph is enabled. Calls are bucketed by the start time of
+-- the child span, time_bucket is the start of the bucket and bucket_width its
+-- width, both in microseconds. error_count is the number of child spans with
+-- an error status and duration_sum the sum of their durations in
+-- nanoseconds. Edges aren't pruned with the spans.
+CREATE TABLE IF NOT EXISTS service_edges(
+    "parent_service" TEXT NOT NULL,
+    "child_service" TEXT NOT NULL,
+    "bucket_width" INTEGER NOT NULL,
+    "time_bucket" INTEGER NOT NULL,
+    "call_count" INTEGER NOT NULL,
+    "error_count" INTEGER NOT NULL,
+    "duration_sum" INTEGER NOT NULL,
+    PRIMARY KEY ("parent_service", "child_service", "bucket_width", "time_bucket")
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS service_edges_time_bucket_idx ON service_edges("time_bucket");
+
+-- service_edge_pending is set on the spans with a parent written while the
+-- service graph is enabled, and cleared once the parent is stored and the
+-- call counted, which is in the same transaction as the child or the parent,
+-- whichever is written last.
+ALTER TABLE spans ADD COLUMN service_edge_pending INTEGER DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS spans_service_edge_pending_idx ON spans("trace_id") WHERE "service_edge_pending" IS NOT NULL;
@@ -0,0 +1,164 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+var resourcesSpec = insertSpec{
+	table: "resources",
+	columns: []string{
+		"resource_id",
+		"__service_name",
+		"attributes",
+		"dropped_attributes_count",
+		"schema_url",
+	},
+	values: []string{
+		"?", "?", "json(?)", "?", "?",
+	},
+}
+
+var scopesSpec = insertSpec{
+	table: "scopes",
+	columns: []string{
+		"scope_id",
+		"name",
+		"version",
+		"attributes",
+		"dropped_attributes_count",
+		"schema_url",
+	},
+	values: []string{
+		"?", "?", "?", "json(?)", "?", "?",
+	},
+}
+
+// inlinedColumns maps the resource and scope columns of the spans table to the
+// column of the normalized tables holding the same value.
+var inlinedColumns = map[string]string{
+	"resource_attributes":                "resources.attributes",
+	"resource_dropped_attributes_count":  "resources.dropped_attributes_count",
+	"instrumentation_library_name":       "scopes.name",
+	"instrumentation_library_version":    "scopes.version",
+	"instrumentation_library_attributes": "scopes.attributes",
+	"resource_schema_url":                "resources.schema_url",
+	"instrumentation_library_schema_url": "scopes.schema_url",
+
+	"instrumentation_library_dropped_attributes_count": "scopes.dropped_attributes_count",
+}
+
+// nanosecondColumns maps the nanosecond timestamp columns of the spans table
+// to the microsecond column they fall back to, for spans written without
+// nanosecond timestamps.
+var nanosecondColumns = map[string]string{
+	"start_time_ns": "start_time",
+	"end_time_ns":   "end_time",
+	"__duration_ns": "__duration",
+}
+
+// resourceID returns the content hash identifying a resource in the resources
+// table. attrs are the resource attributes encoded as JSON. The schema URL is
+// only hashed when it is set, so that resources written before it was stored
+// keep the same ID.
+func resourceID(res pcommon.Resource, schemaURL string, attrs []byte) int64 {
+	h := fnv.New64a()
+	h.Write(attrs)
+	_ = binary.Write(h, binary.LittleEndian, res.DroppedAttributesCount())
+	if schemaURL != "" {
+		h.Write([]byte{0})
+		h.Write([]byte(schemaURL))
+	}
+	return int64(h.Sum64())
+}
+
+// scopeID returns the content hash identifying a scope in the scopes table.
+// attrs are the scope attributes encoded as JSON. Like with resources, the
+// fields that weren't stored at first are only hashed when they are set.
+func scopeID(scope pcommon.InstrumentationScope, schemaURL string, attrs []byte) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(scope.Name()))
+	h.Write([]byte{0})
+	h.Write([]byte(scope.Version()))
+	h.Write([]byte{0})
+	h.Write(attrs)
+	if scope.DroppedAttributesCount() != 0 || schemaURL != "" {
+		h.Write([]byte{0})
+		_ = binary.Write(h, binary.LittleEndian, scope.DroppedAttributesCount())
+		h.Write([]byte(schemaURL))
+	}
+	return int64(h.Sum64())
+}
+
+// ensureDenormalizedView (re)creates the spans_denormalized view, which has
+// the same columns as the spans table but with the nanosecond timestamps
+// falling back to the microsecond ones, and the resource and scope columns
+// filled from the normalized tables when they aren't inlined. It is created
+// from the current columns of the spans table so that it includes the hoisted
+// columns.
+func ensureDenormalizedView(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info('spans') ORDER BY cid;")
+	if err != nil {
+		return fmt.Errorf("failed to list columns of the spans table: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to list columns of the spans table: %w", err)
+		}
+		if normalized, ok := inlinedColumns[name]; ok {
+			columns = append(columns, fmt.Sprintf(`coalesce(spans."%s", %s) AS "%s"`, name, normalized, name))
+		} else if micro, ok := nanosecondColumns[name]; ok {
+			columns = append(columns, fmt.Sprintf(`coalesce(spans."%s", spans."%s" * 1000) AS "%s"`, name, micro, name))
+		} else {
+			columns = append(columns, fmt.Sprintf(`spans."%s"`, name))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list columns of the spans table: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	stmts := []string{
+		"DROP VIEW IF EXISTS spans_denormalized;",
+		"CREATE VIEW spans_denormalized AS SELECT " + strings.Join(columns, ", ") + ` FROM spans
+LEFT JOIN resources ON resources.resource_id = spans.resource_id
+LEFT JOIN scopes ON scopes.scope_id = spans.scope_id;`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to create the spans_denormalized view: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// deleteOrphans deletes the resources and scopes no span refers to anymore.
+func deleteOrphans(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM resources WHERE resource_id NOT IN (SELECT resource_id FROM spans WHERE resource_id IS NOT NULL);"); err != nil {
+		return fmt.Errorf("failed to delete orphan resources: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM scopes WHERE scope_id NOT IN (SELECT scope_id FROM spans WHERE scope_id IS NOT NULL);"); err != nil {
+		return fmt.Errorf("failed to delete orphan scopes: %w", err)
+	}
+	return nil
+}
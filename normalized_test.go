@@ -0,0 +1,69 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExporterNormalizedSchema(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Schema = SchemaNormalized
+	cfg.RowsPerStatement = 10
+	cfg.HoistedAttributes = []HoistedAttribute{{Source: HoistedSourceResource, Key: "host.name"}}
+	ex, err := newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 3)))
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(1, 3)))
+
+	var resources, scopes int
+	require.NoError(t, db.QueryRow("select count(1) from resources;").Scan(&resources))
+	require.NoError(t, db.QueryRow("select count(1) from scopes;").Scan(&scopes))
+	assert.Equal(t, 1, resources)
+	assert.Equal(t, 1, scopes)
+
+	var inlined int
+	require.NoError(t, db.QueryRow("select count(1) from spans where resource_attributes is not null or instrumentation_library_name is not null;").Scan(&inlined))
+	assert.Equal(t, 0, inlined)
+
+	// the view has the same shape as the denormalized spans table
+	var rattrs, scopeName, host string
+	var total int
+	err = db.QueryRow(`select resource_attributes, instrumentation_library_name, host_name, count(1)
+		from spans_denormalized group by 1, 2, 3;`).Scan(&rattrs, &scopeName, &host, &total)
+	require.NoError(t, err)
+	assert.Equal(t, `{"host.name":"localhost","service.name":"bench-service"}`, rattrs)
+	assert.Equal(t, "bench-scope", scopeName)
+	assert.Equal(t, "localhost", host)
+	assert.Equal(t, 6, total)
+}
+
+func Test_ExporterDenormalizedView(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	ex, err := newSqliteExporterWithDB(db, createDefaultConfig().(*Config))
+	require.NoError(t, err)
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 3)))
+
+	var resources, total int
+	require.NoError(t, db.QueryRow("select count(1) from resources;").Scan(&resources))
+	assert.Equal(t, 0, resources)
+	require.NoError(t, db.QueryRow("select count(1) from spans_denormalized where instrumentation_library_name = 'bench-scope';").Scan(&total))
+	assert.Equal(t, 3, total)
+}
@@ -0,0 +1,258 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// anyValueFields are the fields of an OTLP AnyValue, one of which is set in
+// every non-empty value written with the otlp_json attribute encoding.
+var anyValueFields = map[string]bool{
+	"stringValue": true,
+	"boolValue":   true,
+	"intValue":    true,
+	"doubleValue": true,
+	"bytesValue":  true,
+	"arrayValue":  true,
+	"kvlistValue": true,
+}
+
+// decodeMap decodes the JSON-encoded attributes into m, in the order of their
+// keys. Attributes written with the otlp_json encoding are decoded with their
+// exact type. Otherwise numbers without a fractional part are decoded as
+// integers.
+func decodeMap(m pcommon.Map, attrs string) error {
+	if attrs == "" {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(attrs))
+	dec.UseNumber()
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	if isOTLPMap(raw) {
+		return putOTLPMap(m, raw)
+	}
+	putMap(m, raw)
+	return nil
+}
+
+// isOTLPMap reports whether every attribute is an AnyValue, in which case
+// the attributes were written with the otlp_json encoding. With the json
+// encoding, this only happens if every attribute is a map with at most one
+// key named like an AnyValue field.
+func isOTLPMap(raw map[string]any) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	for _, v := range raw {
+		obj, ok := v.(map[string]any)
+		if !ok || len(obj) > 1 {
+			return false
+		}
+		for field := range obj {
+			if !anyValueFields[field] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func putMap(m pcommon.Map, raw map[string]any) {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m.EnsureCapacity(len(keys))
+	for _, k := range keys {
+		putValue(m.PutEmpty(k), raw[k])
+	}
+}
+
+func putValue(dest pcommon.Value, v any) {
+	switch v := v.(type) {
+	case string:
+		dest.SetStr(v)
+	case bool:
+		dest.SetBool(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			dest.SetInt(i)
+			return
+		}
+		f, _ := v.Float64()
+		dest.SetDouble(f)
+	case map[string]any:
+		putMap(dest.SetEmptyMap(), v)
+	case []any:
+		s := dest.SetEmptySlice()
+		s.EnsureCapacity(len(v))
+		for _, e := range v {
+			putValue(s.AppendEmpty(), e)
+		}
+	}
+}
+
+func putOTLPMap(m pcommon.Map, raw map[string]any) error {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m.EnsureCapacity(len(keys))
+	for _, k := range keys {
+		if err := putAnyValue(m.PutEmpty(k), raw[k]); err != nil {
+			return fmt.Errorf("invalid value for attribute %q: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// putAnyValue sets dest to the value of an AnyValue decoded from its JSON
+// encoding.
+func putAnyValue(dest pcommon.Value, v any) error {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected an object, got %T", v)
+	}
+
+	for field, fv := range obj {
+		switch field {
+		case "stringValue":
+			s, ok := fv.(string)
+			if !ok {
+				return fmt.Errorf("expected a string for stringValue, got %T", fv)
+			}
+			dest.SetStr(s)
+		case "boolValue":
+			b, ok := fv.(bool)
+			if !ok {
+				return fmt.Errorf("expected a bool for boolValue, got %T", fv)
+			}
+			dest.SetBool(b)
+		case "intValue":
+			// 64-bit integers are encoded as strings, but numbers are
+			// accepted as well.
+			var s string
+			switch fv := fv.(type) {
+			case string:
+				s = fv
+			case json.Number:
+				s = fv.String()
+			default:
+				return fmt.Errorf("expected a string for intValue, got %T", fv)
+			}
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			dest.SetInt(i)
+		case "doubleValue":
+			f, err := parseDouble(fv)
+			if err != nil {
+				return err
+			}
+			dest.SetDouble(f)
+		case "bytesValue":
+			s, ok := fv.(string)
+			if !ok {
+				return fmt.Errorf("expected a string for bytesValue, got %T", fv)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return err
+			}
+			dest.SetEmptyBytes().FromRaw(b)
+		case "arrayValue":
+			values, err := anyValueList(fv)
+			if err != nil {
+				return err
+			}
+			s := dest.SetEmptySlice()
+			s.EnsureCapacity(len(values))
+			for _, e := range values {
+				if err := putAnyValue(s.AppendEmpty(), e); err != nil {
+					return err
+				}
+			}
+		case "kvlistValue":
+			values, err := anyValueList(fv)
+			if err != nil {
+				return err
+			}
+			m := dest.SetEmptyMap()
+			m.EnsureCapacity(len(values))
+			for _, e := range values {
+				kv, ok := e.(map[string]any)
+				if !ok {
+					return fmt.Errorf("expected an object in kvlistValue, got %T", e)
+				}
+				k, _ := kv["key"].(string)
+				// a key-value pair without a value has an empty value.
+				value, ok := kv["value"]
+				if !ok {
+					value = map[string]any{}
+				}
+				if err := putAnyValue(m.PutEmpty(k), value); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unknown field %q", field)
+		}
+	}
+	// an object without any field is an empty value, which dest already is.
+	return nil
+}
+
+// anyValueList returns the values of an ArrayValue or KeyValueList, whose
+// values field is omitted when empty.
+func anyValueList(v any) ([]any, error) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", v)
+	}
+	values, ok := obj["values"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := values.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array for values, got %T", values)
+	}
+	return list, nil
+}
+
+func parseDouble(v any) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("expected a number for doubleValue, got %T", v)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"go.wperron.io/sqliteexporter/internal/critpath"
+)
+
+const criticalPathSpansQuery = `SELECT span_id, parent_span_id, name, __service_name, start_time_ns, end_time_ns, self_time_ns
+FROM spans_denormalized
+WHERE trace_id = ?;`
+
+// PathSegment is a part of the critical path of a trace spent in a span,
+// outside of any of its children on the path.
+type PathSegment struct {
+	SpanID      pcommon.SpanID
+	SpanName    string
+	ServiceName string
+
+	Start time.Time
+	End   time.Time
+
+	// SelfTime is the duration of the whole span minus the union of the
+	// intervals of its children, clipped to the span.
+	SelfTime time.Duration
+}
+
+// CriticalPath returns the critical path of the trace, ordered by start time.
+// The segments are contiguous and cover the root span of the trace, at every
+// point in time the path is in the child that finished last among the
+// children running at that point, children are clipped to their parent. It is
+// computed from the stored spans, so it is the same as the critical_path
+// table maintained by the exporter but is also available for traces written
+// before the table existed. It returns ErrNotFound when no span of the trace
+// is stored, and no segment when its root span isn't.
+func (d *DB) CriticalPath(ctx context.Context, traceID pcommon.TraceID) ([]PathSegment, error) {
+	rows, err := d.db.QueryContext(ctx, criticalPathSpansQuery, traceID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spans: %w", err)
+	}
+	defer rows.Close()
+
+	type spanInfo struct {
+		name, service string
+		selfTime      time.Duration
+	}
+	var spans []critpath.Span
+	infos := make(map[pcommon.SpanID]spanInfo)
+	for rows.Next() {
+		var (
+			spanID, parentID []byte
+			name, service    sql.NullString
+			span             critpath.Span
+			selfTime         sql.NullInt64
+		)
+		if err := rows.Scan(&spanID, &parentID, &name, &service, &span.Start, &span.End, &selfTime); err != nil {
+			return nil, fmt.Errorf("failed to scan span: %w", err)
+		}
+		span.SpanID = spanIDFromBytes(spanID)
+		span.ParentSpanID = spanIDFromBytes(parentID)
+		spans = append(spans, span)
+		infos[span.SpanID] = spanInfo{name: name.String, service: service.String, selfTime: time.Duration(selfTime.Int64)}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query spans: %w", err)
+	}
+
+	if len(spans) == 0 {
+		return nil, ErrNotFound
+	}
+
+	segments := critpath.Compute(spans)
+	path := make([]PathSegment, 0, len(segments))
+	for _, seg := range segments {
+		info := infos[seg.SpanID]
+		path = append(path, PathSegment{
+			SpanID:      seg.SpanID,
+			SpanName:    info.name,
+			ServiceName: info.service,
+			Start:       time.Unix(0, seg.Start).UTC(),
+			End:         time.Unix(0, seg.End).UTC(),
+			SelfTime:    info.selfTime,
+		})
+	}
+	return path, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+
+// Package query reads traces back out of a database written by the sqlite
+// exporter.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go.wperron.io/sqliteexporter/internal/sqlitedb"
+)
+
+// minSchemaVersion is the first migration of the exporter with every column
+// read by this package.
+const minSchemaVersion = 20240412101834
+
+// ErrNotFound is returned when no span of the requested trace is stored.
+var ErrNotFound = errors.New("trace not found")
+
+// SchemaVersionError is returned by Open when the database was migrated by a
+// version of the exporter older than this package.
+type SchemaVersionError struct {
+	// Version is the schema version of the database, MinVersion the oldest
+	// version read by this package.
+	Version    int64
+	MinVersion int64
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("database schema version %d is older than %d, run the exporter to migrate it", e.Version, e.MinVersion)
+}
+
+// DB reads from a database written by the sqlite exporter.
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens the database at path read-only. The database must have been
+// migrated by a version of the exporter at least as recent as this package.
+func Open(ctx context.Context, path string) (*DB, error) {
+	dsn := (&url.URL{Scheme: "file", Opaque: path, RawQuery: "mode=ro"}).String()
+	db := sqlitedb.Open(dsn, []string{"PRAGMA busy_timeout = 5000;"})
+
+	version, err := schemaVersion(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version < minSchemaVersion {
+		db.Close()
+		return nil, &SchemaVersionError{Version: version, MinVersion: minSchemaVersion}
+	}
+
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// database by the exporter.
+func (d *DB) SchemaVersion(ctx context.Context) (int64, error) {
+	return schemaVersion(ctx, d.db)
+}
+
+func schemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+	var dirty bool
+	err := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations_sqliteexporter LIMIT 1;").Scan(&version, &dirty)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the schema version: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("database schema version %d is dirty", version)
+	}
+	return version, nil
+}
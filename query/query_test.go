@@ -0,0 +1,665 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	sqliteexporter "go.wperron.io/sqliteexporter"
+	"go.wperron.io/sqliteexporter/internal/sqlitedb"
+)
+
+var (
+	checkoutTraceID = pcommon.TraceID{0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}
+	healthTraceID   = pcommon.TraceID{0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02}
+	orphanTraceID   = pcommon.TraceID{0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03}
+)
+
+// writeTraces writes the traces with the sqlite exporter to a new database
+// and returns its path.
+func writeTraces(t *testing.T, schema string, traces ptrace.Traces) string {
+	return writeTracesWithConfig(t, func(cfg *sqliteexporter.Config) { cfg.Schema = schema }, traces)
+}
+
+func writeTracesWithConfig(t *testing.T, configure func(*sqliteexporter.Config), traces ptrace.Traces) string {
+	ctx := context.Background()
+	factory := sqliteexporter.NewFactory()
+	cfg := factory.CreateDefaultConfig().(*sqliteexporter.Config)
+	cfg.Path = filepath.Join(t.TempDir(), "traces.db")
+	cfg.QueueSettings.Enabled = false
+	configure(cfg)
+
+	exp, err := factory.CreateTracesExporter(ctx, exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, exp.ConsumeTraces(ctx, traces))
+	require.NoError(t, exp.Shutdown(ctx))
+	return cfg.Path
+}
+
+func openTestDB(t *testing.T, path string) *DB {
+	db, err := Open(context.Background(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testTraces returns three traces: a checkout request going through two
+// services, a health check, and a trace whose root span is missing.
+func testTraces(now time.Time) ptrace.Traces {
+	traces := ptrace.NewTraces()
+
+	frontend := traces.ResourceSpans().AppendEmpty()
+	frontend.Resource().Attributes().PutStr("service.name", "frontend")
+	frontend.Resource().Attributes().PutStr("deployment.environment", "prod")
+	frontendScope := frontend.ScopeSpans().AppendEmpty()
+	frontendScope.Scope().SetName("net/http")
+	frontendScope.Scope().SetVersion("v1.0.0")
+
+	checkout := frontendScope.Spans().AppendEmpty()
+	checkout.SetTraceID(checkoutTraceID)
+	checkout.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x01})
+	checkout.TraceState().FromRaw("rojo=00f067aa0ba902b7")
+	checkout.SetName("POST /checkout")
+	checkout.SetKind(ptrace.SpanKindServer)
+	checkout.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second)))
+	checkout.SetEndTimestamp(pcommon.NewTimestampFromTime(now))
+	checkout.Status().SetCode(ptrace.StatusCodeError)
+	checkout.Status().SetMessage("payment failed")
+	checkout.Attributes().PutStr("http.method", "POST")
+	checkout.Attributes().PutInt("http.status_code", 500)
+	checkout.Attributes().PutDouble("cart.total", 12.5)
+	checkout.Attributes().PutBool("user.premium", true)
+	checkout.SetDroppedAttributesCount(1)
+	event := checkout.Events().AppendEmpty()
+	event.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-500 * time.Millisecond)))
+	event.SetName("exception")
+	event.Attributes().PutStr("exception.message", "card declined")
+	link := checkout.Links().AppendEmpty()
+	link.SetTraceID(healthTraceID)
+	link.SetSpanID(pcommon.SpanID{0x02, 0, 0, 0, 0, 0, 0, 0x01})
+	link.Attributes().PutStr("link.kind", "follows")
+
+	health := frontendScope.Spans().AppendEmpty()
+	health.SetTraceID(healthTraceID)
+	health.SetSpanID(pcommon.SpanID{0x02, 0, 0, 0, 0, 0, 0, 0x01})
+	health.SetName("GET /health")
+	health.SetKind(ptrace.SpanKindServer)
+	health.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-2 * time.Second)))
+	health.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-2*time.Second + time.Millisecond)))
+	health.Status().SetCode(ptrace.StatusCodeOk)
+
+	payments := traces.ResourceSpans().AppendEmpty()
+	payments.Resource().Attributes().PutStr("service.name", "payments")
+	paymentsScope := payments.ScopeSpans().AppendEmpty()
+	paymentsScope.Scope().SetName("grpc")
+
+	charge := paymentsScope.Spans().AppendEmpty()
+	charge.SetTraceID(checkoutTraceID)
+	charge.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x02})
+	charge.SetParentSpanID(checkout.SpanID())
+	charge.SetName("Charge")
+	charge.SetKind(ptrace.SpanKindClient)
+	charge.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-900 * time.Millisecond)))
+	charge.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-100 * time.Millisecond)))
+	charge.Status().SetCode(ptrace.StatusCodeError)
+	charge.Attributes().PutStr("rpc.method", "Charge")
+
+	orphan := paymentsScope.Spans().AppendEmpty()
+	orphan.SetTraceID(orphanTraceID)
+	orphan.SetSpanID(pcommon.SpanID{0x03, 0, 0, 0, 0, 0, 0, 0x02})
+	orphan.SetParentSpanID(pcommon.SpanID{0x03, 0, 0, 0, 0, 0, 0, 0x01})
+	orphan.SetName("Refund")
+	orphan.SetKind(ptrace.SpanKindClient)
+	orphan.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-3 * time.Second)))
+	orphan.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-3*time.Second + 10*time.Millisecond)))
+
+	return traces
+}
+
+func TestGetTrace(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	for _, schema := range []string{sqliteexporter.SchemaDenormalized, sqliteexporter.SchemaNormalized} {
+		t.Run(schema, func(t *testing.T) {
+			db := openTestDB(t, writeTraces(t, schema, testTraces(now)))
+
+			traces, err := db.GetTrace(ctx, checkoutTraceID)
+			require.NoError(t, err)
+			require.Equal(t, 2, traces.ResourceSpans().Len())
+			assert.Equal(t, 2, traces.SpanCount())
+
+			frontend := traces.ResourceSpans().At(0)
+			assert.Equal(t, map[string]any{"service.name": "frontend", "deployment.environment": "prod"}, frontend.Resource().Attributes().AsRaw())
+			require.Equal(t, 1, frontend.ScopeSpans().Len())
+			assert.Equal(t, "net/http", frontend.ScopeSpans().At(0).Scope().Name())
+			assert.Equal(t, "v1.0.0", frontend.ScopeSpans().At(0).Scope().Version())
+
+			checkout := frontend.ScopeSpans().At(0).Spans().At(0)
+			assert.Equal(t, checkoutTraceID, checkout.TraceID())
+			assert.Equal(t, pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x01}, checkout.SpanID())
+			assert.True(t, checkout.ParentSpanID().IsEmpty())
+			assert.Equal(t, "rojo=00f067aa0ba902b7", checkout.TraceState().AsRaw())
+			assert.Equal(t, "POST /checkout", checkout.Name())
+			assert.Equal(t, ptrace.SpanKindServer, checkout.Kind())
+			assert.Equal(t, pcommon.NewTimestampFromTime(now.Add(-time.Second)), checkout.StartTimestamp())
+			assert.Equal(t, pcommon.NewTimestampFromTime(now), checkout.EndTimestamp())
+			assert.Equal(t, ptrace.StatusCodeError, checkout.Status().Code())
+			assert.Equal(t, "payment failed", checkout.Status().Message())
+			assert.Equal(t, uint32(1), checkout.DroppedAttributesCount())
+
+			// attribute types survive the JSON encoding.
+			assert.Equal(t, map[string]any{
+				"http.method":      "POST",
+				"http.status_code": int64(500),
+				"cart.total":       12.5,
+				"user.premium":     true,
+			}, checkout.Attributes().AsRaw())
+
+			require.Equal(t, 1, checkout.Events().Len())
+			assert.Equal(t, "exception", checkout.Events().At(0).Name())
+			assert.Equal(t, pcommon.NewTimestampFromTime(now.Add(-500*time.Millisecond)), checkout.Events().At(0).Timestamp())
+			assert.Equal(t, map[string]any{"exception.message": "card declined"}, checkout.Events().At(0).Attributes().AsRaw())
+
+			require.Equal(t, 1, checkout.Links().Len())
+			assert.Equal(t, healthTraceID, checkout.Links().At(0).TraceID())
+			assert.Equal(t, pcommon.SpanID{0x02, 0, 0, 0, 0, 0, 0, 0x01}, checkout.Links().At(0).SpanID())
+			assert.Equal(t, map[string]any{"link.kind": "follows"}, checkout.Links().At(0).Attributes().AsRaw())
+
+			payments := traces.ResourceSpans().At(1)
+			assert.Equal(t, "grpc", payments.ScopeSpans().At(0).Scope().Name())
+			charge := payments.ScopeSpans().At(0).Spans().At(0)
+			assert.Equal(t, "Charge", charge.Name())
+			assert.Equal(t, checkout.SpanID(), charge.ParentSpanID())
+			assert.Equal(t, ptrace.SpanKindClient, charge.Kind())
+			assert.Zero(t, charge.Events().Len())
+		})
+	}
+}
+
+// roundTripTrace returns a trace setting every field the exporter stores.
+// Attributes are sorted by key, like the spans read back from the database.
+func roundTripTrace(now time.Time) ptrace.Traces {
+	traces := ptrace.NewTraces()
+
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.24.0")
+	rs.Resource().Attributes().PutStr("host.name", "localhost")
+	rs.Resource().Attributes().PutStr("service.name", "frontend")
+	rs.Resource().SetDroppedAttributesCount(2)
+
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.SetSchemaUrl("https://opentelemetry.io/schemas/1.21.0")
+	ss.Scope().SetName("net/http")
+	ss.Scope().SetVersion("v1.0.0")
+	ss.Scope().Attributes().PutStr("library.language", "go")
+	ss.Scope().SetDroppedAttributesCount(1)
+
+	root := ss.Spans().AppendEmpty()
+	root.SetTraceID(checkoutTraceID)
+	root.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x01})
+	root.TraceState().FromRaw("rojo=00f067aa0ba902b7")
+	root.SetName("POST /checkout")
+	root.SetKind(ptrace.SpanKindServer)
+	root.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second)))
+	root.SetEndTimestamp(pcommon.NewTimestampFromTime(now))
+	root.Status().SetCode(ptrace.StatusCodeError)
+	root.Status().SetMessage("payment failed")
+	root.Attributes().PutDouble("cart.total", 12.5)
+	root.Attributes().PutStr("http.method", "POST")
+	root.Attributes().PutInt("http.status_code", 500)
+	items := root.Attributes().PutEmptySlice("items")
+	items.AppendEmpty().SetInt(1)
+	items.AppendEmpty().SetInt(2)
+	user := root.Attributes().PutEmptyMap("user")
+	user.PutStr("id", "42")
+	user.PutBool("premium", true)
+	root.SetDroppedAttributesCount(3)
+	root.SetDroppedEventsCount(4)
+	root.SetDroppedLinksCount(5)
+	for i, name := range []string{"validated", "exception"} {
+		event := root.Events().AppendEmpty()
+		event.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(time.Duration(i-500) * time.Millisecond)))
+		event.SetName(name)
+		event.Attributes().PutInt("attempt", int64(i))
+		event.SetDroppedAttributesCount(uint32(i))
+	}
+	link := root.Links().AppendEmpty()
+	link.SetTraceID(healthTraceID)
+	link.SetSpanID(pcommon.SpanID{0x02, 0, 0, 0, 0, 0, 0, 0x01})
+	link.TraceState().FromRaw("congo=t61rcWkgMzE")
+	link.Attributes().PutStr("link.kind", "follows")
+	link.SetDroppedAttributesCount(6)
+
+	child := ss.Spans().AppendEmpty()
+	child.SetTraceID(checkoutTraceID)
+	child.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x02})
+	child.SetParentSpanID(root.SpanID())
+	child.SetName("validate")
+	child.SetKind(ptrace.SpanKindInternal)
+	child.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-900 * time.Millisecond)))
+	child.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-800 * time.Millisecond)))
+	child.Status().SetCode(ptrace.StatusCodeOk)
+
+	other := rs.ScopeSpans().AppendEmpty()
+	other.Scope().SetName("database/sql")
+
+	query := other.Spans().AppendEmpty()
+	query.SetTraceID(checkoutTraceID)
+	query.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x03})
+	query.SetParentSpanID(child.SpanID())
+	query.SetName("SELECT")
+	query.SetKind(ptrace.SpanKindClient)
+	query.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-850 * time.Millisecond)))
+	query.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-840 * time.Millisecond)))
+
+	payments := traces.ResourceSpans().AppendEmpty()
+	payments.Resource().Attributes().PutStr("service.name", "payments")
+	charge := payments.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	charge.SetTraceID(checkoutTraceID)
+	charge.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x04})
+	charge.SetParentSpanID(root.SpanID())
+	charge.SetName("Charge")
+	charge.SetKind(ptrace.SpanKindConsumer)
+	charge.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-700 * time.Millisecond)))
+	charge.SetEndTimestamp(pcommon.NewTimestampFromTime(now.Add(-100 * time.Millisecond)))
+
+	return traces
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		configure func(*sqliteexporter.Config)
+		now       time.Time
+	}{
+		{
+			name:      sqliteexporter.SchemaDenormalized,
+			configure: func(cfg *sqliteexporter.Config) { cfg.Schema = sqliteexporter.SchemaDenormalized },
+			now:       time.Now().Truncate(time.Microsecond),
+		},
+		{
+			name:      sqliteexporter.SchemaNormalized,
+			configure: func(cfg *sqliteexporter.Config) { cfg.Schema = sqliteexporter.SchemaNormalized },
+			now:       time.Now().Truncate(time.Microsecond),
+		},
+		{
+			// timestamps keep their sub-microsecond part.
+			name:      "nanoseconds",
+			configure: func(cfg *sqliteexporter.Config) { cfg.TimestampPrecision = sqliteexporter.TimestampPrecisionNano },
+			now:       time.Now().Truncate(time.Microsecond).Add(789 * time.Nanosecond),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := roundTripTrace(tt.now)
+			db := openTestDB(t, writeTracesWithConfig(t, tt.configure, roundTripTrace(tt.now)))
+
+			actual, err := db.GetTrace(ctx, checkoutTraceID)
+			require.NoError(t, err)
+
+			marshaler := &ptrace.JSONMarshaler{}
+			expectedJSON, err := marshaler.MarshalTraces(expected)
+			require.NoError(t, err)
+			actualJSON, err := marshaler.MarshalTraces(actual)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedJSON), string(actualJSON))
+		})
+	}
+}
+
+func TestRoundTripAttributeEncoding(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	// every type that the json encoding can't tell apart, keys sorted.
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "frontend")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(checkoutTraceID)
+	span.SetSpanID(pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x01})
+	span.SetName("POST /checkout")
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second)))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(now))
+	span.Attributes().PutEmptyBytes("bytes").FromRaw([]byte{0xde, 0xad, 0xbe, 0xef})
+	span.Attributes().PutDouble("double", 2)
+	span.Attributes().PutDouble("double.nan", math.NaN())
+	span.Attributes().PutEmpty("empty")
+	span.Attributes().PutInt("int", math.MaxInt64)
+	kvlist := span.Attributes().PutEmptyMap("kvlist")
+	kvlist.PutStr("z", "last")
+	kvlist.PutEmptySlice("a").AppendEmpty().SetDouble(1)
+	span.Attributes().PutEmptySlice("slice")
+	span.Attributes().PutStr("string", "12")
+
+	path := writeTracesWithConfig(t, func(cfg *sqliteexporter.Config) {
+		cfg.AttributeEncoding = sqliteexporter.AttributeEncodingOTLPJSON
+	}, traces)
+	db := openTestDB(t, path)
+
+	actual, err := db.GetTrace(ctx, checkoutTraceID)
+	require.NoError(t, err)
+
+	marshaler := &ptrace.JSONMarshaler{}
+	expectedJSON, err := marshaler.MarshalTraces(traces)
+	require.NoError(t, err)
+	actualJSON, err := marshaler.MarshalTraces(actual)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expectedJSON), string(actualJSON))
+}
+
+func TestGetTraceNotFound(t *testing.T) {
+	db := openTestDB(t, writeTraces(t, sqliteexporter.SchemaDenormalized, testTraces(time.Now())))
+
+	_, err := db.GetTrace(context.Background(), pcommon.TraceID{0xff})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	db := openTestDB(t, writeTraces(t, sqliteexporter.SchemaDenormalized, testTraces(time.Now())))
+
+	_, err := db.db.Exec("DELETE FROM spans;")
+	assert.ErrorContains(t, err, "readonly")
+}
+
+func TestSearchTraces(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	db := openTestDB(t, writeTraces(t, sqliteexporter.SchemaNormalized, testTraces(now)))
+
+	tests := []struct {
+		name     string
+		query    SearchQuery
+		expected []pcommon.TraceID
+	}{
+		{
+			name:     "all",
+			query:    SearchQuery{},
+			expected: []pcommon.TraceID{checkoutTraceID, healthTraceID, orphanTraceID},
+		},
+		{
+			name:     "service",
+			query:    SearchQuery{ServiceName: "payments"},
+			expected: []pcommon.TraceID{checkoutTraceID, orphanTraceID},
+		},
+		{
+			name:     "span name",
+			query:    SearchQuery{SpanName: "GET /health"},
+			expected: []pcommon.TraceID{healthTraceID},
+		},
+		{
+			name:     "duration",
+			query:    SearchQuery{MinDuration: 5 * time.Millisecond, MaxDuration: 500 * time.Millisecond},
+			expected: []pcommon.TraceID{orphanTraceID},
+		},
+		{
+			name:     "time window",
+			query:    SearchQuery{Start: now.Add(-2500 * time.Millisecond), End: now.Add(-time.Second)},
+			expected: []pcommon.TraceID{healthTraceID},
+		},
+		{
+			name:     "status",
+			query:    SearchQuery{StatusCodes: []ptrace.StatusCode{ptrace.StatusCodeOk, ptrace.StatusCodeUnset}},
+			expected: []pcommon.TraceID{healthTraceID, orphanTraceID},
+		},
+		{
+			name:     "span attributes",
+			query:    SearchQuery{Attributes: map[string]any{"http.status_code": 500, "user.premium": true, "cart.total": 12.5}},
+			expected: []pcommon.TraceID{checkoutTraceID},
+		},
+		{
+			name:     "resource attributes",
+			query:    SearchQuery{Attributes: map[string]any{"deployment.environment": "prod"}},
+			expected: []pcommon.TraceID{checkoutTraceID, healthTraceID},
+		},
+		{
+			name:     "filters match the same span",
+			query:    SearchQuery{ServiceName: "payments", SpanName: "POST /checkout"},
+			expected: nil,
+		},
+		{
+			name:     "pagination",
+			query:    SearchQuery{Limit: 1, Offset: 1},
+			expected: []pcommon.TraceID{healthTraceID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaries, err := db.SearchTraces(ctx, tt.query)
+			require.NoError(t, err)
+
+			var ids []pcommon.TraceID
+			for _, s := range summaries {
+				ids = append(ids, s.TraceID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestSearchTracesAttributeEncoding(t *testing.T) {
+	ctx := context.Background()
+	path := writeTracesWithConfig(t, func(cfg *sqliteexporter.Config) {
+		cfg.AttributeEncoding = sqliteexporter.AttributeEncodingOTLPJSON
+	}, testTraces(time.Now()))
+	db := openTestDB(t, path)
+
+	summaries, err := db.SearchTraces(ctx, SearchQuery{Attributes: map[string]any{
+		"http.method":            "POST",
+		"http.status_code":       500,
+		"user.premium":           true,
+		"cart.total":             12.5,
+		"deployment.environment": "prod",
+	}})
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, checkoutTraceID, summaries[0].TraceID)
+
+	// values only match attributes of the same type.
+	summaries, err = db.SearchTraces(ctx, SearchQuery{Attributes: map[string]any{"http.status_code": "500"}})
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+}
+
+func TestSearchTracesSummary(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	db := openTestDB(t, writeTraces(t, sqliteexporter.SchemaDenormalized, testTraces(now)))
+
+	summaries, err := db.SearchTraces(ctx, SearchQuery{ServiceName: "payments"})
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+
+	assert.Equal(t, TraceSummary{
+		TraceID:         checkoutTraceID,
+		RootServiceName: "frontend",
+		RootSpanName:    "POST /checkout",
+		Start:           now.Add(-time.Second).UTC(),
+		Duration:        time.Second,
+		SpanCount:       2,
+		ErrorCount:      2,
+		Services:        []string{"frontend", "payments"},
+	}, summaries[0])
+
+	// the root span of the orphan trace was never stored.
+	assert.Equal(t, TraceSummary{
+		TraceID:   orphanTraceID,
+		Start:     now.Add(-3 * time.Second).UTC(),
+		Duration:  10 * time.Millisecond,
+		SpanCount: 1,
+		Services:  []string{"payments"},
+	}, summaries[1])
+}
+
+func TestSearchTracesInvalidAttribute(t *testing.T) {
+	db := openTestDB(t, writeTraces(t, sqliteexporter.SchemaDenormalized, testTraces(time.Now())))
+
+	_, err := db.SearchTraces(context.Background(), SearchQuery{Attributes: map[string]any{"key": []string{"a"}}})
+	assert.EqualError(t, err, `unsupported type []string for attribute "key"`)
+}
+
+func TestCriticalPath(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+
+	for _, schema := range []string{sqliteexporter.SchemaDenormalized, sqliteexporter.SchemaNormalized} {
+		t.Run(schema, func(t *testing.T) {
+			db := openTestDB(t, writeTraces(t, schema, testTraces(now)))
+
+			path, err := db.CriticalPath(ctx, checkoutTraceID)
+			require.NoError(t, err)
+			checkout := PathSegment{
+				SpanID:      pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x01},
+				SpanName:    "POST /checkout",
+				ServiceName: "frontend",
+				SelfTime:    200 * time.Millisecond,
+			}
+			charge := PathSegment{
+				SpanID:      pcommon.SpanID{0x01, 0, 0, 0, 0, 0, 0, 0x02},
+				SpanName:    "Charge",
+				ServiceName: "payments",
+				Start:       now.Add(-900 * time.Millisecond).UTC(),
+				End:         now.Add(-100 * time.Millisecond).UTC(),
+				SelfTime:    800 * time.Millisecond,
+			}
+			before, after := checkout, checkout
+			before.Start, before.End = now.Add(-time.Second).UTC(), charge.Start
+			after.Start, after.End = charge.End, now.UTC()
+			assert.Equal(t, []PathSegment{before, charge, after}, path)
+
+			// the critical_path table maintained by the exporter has the same
+			// segments.
+			rows, err := db.db.QueryContext(ctx, "SELECT span_id, start_time_ns, end_time_ns FROM critical_path WHERE trace_id = ? ORDER BY position;", checkoutTraceID[:])
+			require.NoError(t, err)
+			defer rows.Close()
+			var stored []PathSegment
+			for rows.Next() {
+				var spanID []byte
+				var start, end int64
+				require.NoError(t, rows.Scan(&spanID, &start, &end))
+				stored = append(stored, PathSegment{SpanID: spanIDFromBytes(spanID), Start: time.Unix(0, start).UTC(), End: time.Unix(0, end).UTC()})
+			}
+			require.NoError(t, rows.Err())
+			require.Len(t, stored, len(path))
+			for i := range path {
+				assert.Equal(t, path[i].SpanID, stored[i].SpanID)
+				assert.Equal(t, path[i].Start, stored[i].Start)
+				assert.Equal(t, path[i].End, stored[i].End)
+			}
+
+			// the root span of the orphan trace was never stored.
+			path, err = db.CriticalPath(ctx, orphanTraceID)
+			require.NoError(t, err)
+			assert.Empty(t, path)
+
+			_, err = db.CriticalPath(ctx, pcommon.TraceID{0xff})
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestServiceGraph(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	db := openTestDB(t, writeTracesWithConfig(t, func(cfg *sqliteexporter.Config) {
+		cfg.ServiceGraph.Enabled = true
+	}, testTraces(now)))
+
+	edges, err := db.ServiceGraph(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []ServiceEdge{
+		{Parent: "frontend", Child: "payments", CallCount: 1, ErrorCount: 1, DurationSum: 800 * time.Millisecond},
+	}, edges)
+
+	// the call started 900ms ago, in a bucket starting less than two minutes
+	// ago.
+	edges, err = db.ServiceGraph(ctx, now.Add(-time.Hour), now.Add(-time.Hour+time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, edges)
+	edges, err = db.ServiceGraph(ctx, now.Add(-2*time.Minute), now)
+	require.NoError(t, err)
+	assert.Len(t, edges, 1)
+}
+
+func TestWriteServiceGraph(t *testing.T) {
+	edges := []ServiceEdge{
+		{Parent: "frontend", Child: "checkout", CallCount: 4, ErrorCount: 1, DurationSum: 40 * time.Millisecond},
+		{Parent: "frontend", Child: `pay"ments`, CallCount: 1, DurationSum: time.Second},
+	}
+
+	var dot strings.Builder
+	require.NoError(t, WriteDOT(&dot, edges))
+	assert.Equal(t, `digraph services {
+  "frontend" -> "checkout" [label="4 calls, 1 errors, avg 10ms"];
+  "frontend" -> "pay\"ments" [label="1 calls, 0 errors, avg 1s"];
+}
+`, dot.String())
+
+	var mermaid strings.Builder
+	require.NoError(t, WriteMermaid(&mermaid, edges))
+	assert.Equal(t, `flowchart LR
+  s0["checkout"]
+  s1["frontend"]
+  s2["pay#quot;ments"]
+  s1 -->|"4 calls, 1 errors, avg 10ms"| s0
+  s1 -->|"1 calls, 0 errors, avg 1s"| s2
+`, mermaid.String())
+}
+
+func TestSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	path := writeTraces(t, sqliteexporter.SchemaDenormalized, testTraces(time.Now()))
+
+	version, err := openTestDB(t, path).SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, version, int64(minSchemaVersion))
+
+	db := sqlitedb.Open(path, nil)
+	defer db.Close()
+	_, err = db.Exec("UPDATE schema_migrations_sqliteexporter SET version = 20240101000000;")
+	require.NoError(t, err)
+
+	_, err = Open(ctx, path)
+	var versionErr *SchemaVersionError
+	require.ErrorAs(t, err, &versionErr)
+	assert.Equal(t, &SchemaVersionError{Version: 20240101000000, MinVersion: minSchemaVersion}, versionErr)
+}
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Microsecond)
+	db := openTestDB(t, writeTraces(t, sqliteexporter.SchemaDenormalized, testTraces(now)))
+
+	stats, err := db.Stats(ctx)
+	require.NoError(t, err)
+
+	var names []string
+	rows := make(map[string]int64)
+	for _, ts := range stats.Tables {
+		names = append(names, ts.Name)
+		rows[ts.Name] = ts.Rows
+	}
+	assert.Equal(t, int64(4), rows["spans"])
+	assert.Equal(t, int64(1), rows["events"])
+	assert.Equal(t, int64(1), rows["links"])
+	assert.Equal(t, int64(3), rows["traces"])
+	assert.NotContains(t, rows, "sqlite_sequence")
+	assert.IsIncreasing(t, names)
+
+	assert.Positive(t, stats.Size)
+	assert.Equal(t, now.Add(-3*time.Second).UTC(), stats.Start)
+	assert.Equal(t, now.UTC(), stats.End)
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// defaultLimit is the number of traces returned when SearchQuery.Limit is 0.
+const defaultLimit = 20
+
+// SearchQuery filters the traces returned by SearchTraces. A trace matches
+// when at least one of its spans matches every filter that is set.
+type SearchQuery struct {
+	// ServiceName is the service.name resource attribute of the span.
+	ServiceName string
+
+	// SpanName is the name of the span.
+	SpanName string
+
+	// MinDuration and MaxDuration bound the duration of the span. Zero values
+	// leave that end of the range open.
+	MinDuration time.Duration
+	MaxDuration time.Duration
+
+	// Start and End bound the start time of the span, End is exclusive. Zero
+	// values leave that end of the window open.
+	Start time.Time
+	End   time.Time
+
+	// StatusCodes are the accepted status codes of the span. Any status is
+	// accepted when empty.
+	StatusCodes []ptrace.StatusCode
+
+	// Attributes are compared for equality with the span attributes, or the
+	// resource attributes when the span doesn't have the key. Values must be
+	// strings, bools, integers or floats.
+	Attributes map[string]any
+
+	// Limit is the maximum number of traces returned, defaults to 20. Offset
+	// is the number of traces skipped, to read the following pages.
+	Limit  int
+	Offset int
+}
+
+// TraceSummary describes a trace returned by SearchTraces.
+type TraceSummary struct {
+	TraceID pcommon.TraceID
+
+	// RootServiceName and RootSpanName are empty when the root span of the
+	// trace hasn't been stored.
+	RootServiceName string
+	RootSpanName    string
+
+	// Start is the earliest start time of the spans of the trace, Duration
+	// the time until the latest end time.
+	Start    time.Time
+	Duration time.Duration
+
+	SpanCount int
+
+	// ErrorCount is the number of spans with an error status.
+	ErrorCount int
+
+	// Services are the sorted service names of the spans of the trace.
+	Services []string
+}
+
+// SearchTraces returns the traces matching q, the most recent first. The
+// summaries are read from the traces table maintained by the exporter, so
+// listing the most recent traces without any filter doesn't read the spans.
+func (d *DB) SearchTraces(ctx context.Context, q SearchQuery) ([]TraceSummary, error) {
+	conds, args, err := q.conditions()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	args = append(args, limit, q.Offset)
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE trace_id IN (SELECT trace_id FROM spans_denormalized WHERE " + strings.Join(conds, " AND ") + ")"
+	}
+
+	query := fmt.Sprintf(`SELECT
+	trace_id,
+	root_service_name,
+	root_span_name,
+	start_time_ns,
+	end_time_ns,
+	span_count,
+	error_count,
+	services
+FROM traces
+%s
+ORDER BY start_time_ns DESC, trace_id
+LIMIT ? OFFSET ?;`, where)
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search traces: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []TraceSummary
+	for rows.Next() {
+		var (
+			traceID               []byte
+			rootService, rootName sql.NullString
+			start, end            int64
+			services              string
+			summary               TraceSummary
+		)
+		if err := rows.Scan(&traceID, &rootService, &rootName, &start, &end, &summary.SpanCount, &summary.ErrorCount, &services); err != nil {
+			return nil, fmt.Errorf("failed to scan trace: %w", err)
+		}
+		if err := json.Unmarshal([]byte(services), &summary.Services); err != nil {
+			return nil, fmt.Errorf("failed to decode services of trace: %w", err)
+		}
+		summary.TraceID = traceIDFromBytes(traceID)
+		summary.RootServiceName = rootService.String
+		summary.RootSpanName = rootName.String
+		summary.Start = time.Unix(0, start).UTC()
+		summary.Duration = time.Duration(end - start)
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search traces: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// conditions returns the WHERE conditions on the spans_denormalized view
+// matching the spans selected by q, along with their arguments.
+func (q SearchQuery) conditions() ([]string, []any, error) {
+	var conds []string
+	var args []any
+
+	if q.ServiceName != "" {
+		conds = append(conds, "__service_name = ?")
+		args = append(args, q.ServiceName)
+	}
+	if q.SpanName != "" {
+		conds = append(conds, "name = ?")
+		args = append(args, q.SpanName)
+	}
+	if q.MinDuration > 0 {
+		conds = append(conds, "__duration_ns >= ?")
+		args = append(args, q.MinDuration.Nanoseconds())
+	}
+	if q.MaxDuration > 0 {
+		conds = append(conds, "__duration_ns <= ?")
+		args = append(args, q.MaxDuration.Nanoseconds())
+	}
+	if !q.Start.IsZero() {
+		conds = append(conds, "start_time >= ?")
+		args = append(args, q.Start.UnixMicro())
+	}
+	if !q.End.IsZero() {
+		conds = append(conds, "start_time < ?")
+		args = append(args, q.End.UnixMicro())
+	}
+	if len(q.StatusCodes) > 0 {
+		placeholders := make([]string, len(q.StatusCodes))
+		for i, code := range q.StatusCodes {
+			placeholders[i] = "?"
+			args = append(args, int32(code))
+		}
+		conds = append(conds, fmt.Sprintf("status_code IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	for k, v := range q.Attributes {
+		if strings.Contains(k, `"`) {
+			return nil, nil, fmt.Errorf("attribute key %q must not contain double quotes", k)
+		}
+		// attributes written with the otlp_json encoding are unwrapped from
+		// the AnyValue field of the searched type.
+		var field string
+		switch v.(type) {
+		case string:
+			field = "stringValue"
+		case bool:
+			field = "boolValue"
+		case int, int32, int64:
+			field = "intValue"
+		case float32, float64:
+			field = "doubleValue"
+		default:
+			return nil, nil, fmt.Errorf("unsupported type %T for attribute %q", v, k)
+		}
+		path := fmt.Sprintf(`$."%s"`, k)
+		typed := fmt.Sprintf(`$."%s".%s`, k, field)
+		value := "coalesce(json_extract(%[1]s, ?), json_extract(%[1]s, ?))"
+		if field == "intValue" {
+			// 64-bit integers are encoded as strings.
+			value = "coalesce(CAST(json_extract(%[1]s, ?) AS INTEGER), json_extract(%[1]s, ?))"
+		}
+		conds = append(conds, fmt.Sprintf("coalesce(%s, %s) = ?", fmt.Sprintf(value, "attributes"), fmt.Sprintf(value, "resource_attributes")))
+		args = append(args, typed, path, typed, path, v)
+	}
+
+	return conds, args, nil
+}
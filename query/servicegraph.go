@@ -0,0 +1,141 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ServiceEdge is the calls from a service to another, a span of the child
+// service whose parent span belongs to the parent service.
+type ServiceEdge struct {
+	Parent string
+	Child  string
+
+	CallCount int64
+
+	// ErrorCount is the number of calls whose child span has an error status.
+	ErrorCount int64
+
+	// DurationSum is the sum of the durations of the child spans.
+	DurationSum time.Duration
+}
+
+// ServiceGraph returns the calls between services in the time buckets
+// starting between start and end, end being exclusive. Zero values leave that
+// end of the window open. The edges are read from the service_edges table,
+// which is only written by the exporter when service_graph is enabled, and
+// are sorted by parent then child service.
+func (d *DB) ServiceGraph(ctx context.Context, start, end time.Time) ([]ServiceEdge, error) {
+	var conds []string
+	var args []any
+	if !start.IsZero() {
+		conds = append(conds, "time_bucket >= ?")
+		args = append(args, start.UnixMicro())
+	}
+	if !end.IsZero() {
+		conds = append(conds, "time_bucket < ?")
+		args = append(args, end.UnixMicro())
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	query := fmt.Sprintf(`SELECT parent_service, child_service, sum(call_count), sum(error_count), sum(duration_sum)
+FROM service_edges
+%s
+GROUP BY parent_service, child_service
+ORDER BY parent_service, child_service;`, where)
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service edges: %w", err)
+	}
+	defer rows.Close()
+
+	var edges []ServiceEdge
+	for rows.Next() {
+		var e ServiceEdge
+		var durationSum int64
+		if err := rows.Scan(&e.Parent, &e.Child, &e.CallCount, &e.ErrorCount, &durationSum); err != nil {
+			return nil, fmt.Errorf("failed to scan service edge: %w", err)
+		}
+		e.DurationSum = time.Duration(durationSum)
+		edges = append(edges, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query service edges: %w", err)
+	}
+
+	return edges, nil
+}
+
+// label describes the calls of the edge.
+func (e ServiceEdge) label() string {
+	var avg time.Duration
+	if e.CallCount > 0 {
+		avg = e.DurationSum / time.Duration(e.CallCount)
+	}
+	return fmt.Sprintf("%d calls, %d errors, avg %s", e.CallCount, e.ErrorCount, avg)
+}
+
+// WriteDOT writes the service graph of the edges in the Graphviz DOT
+// language.
+func WriteDOT(w io.Writer, edges []ServiceEdge) error {
+	var b strings.Builder
+	b.WriteString("digraph services {\n")
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotQuote(e.Parent), dotQuote(e.Child), dotQuote(e.label()))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a double quoted DOT identifier.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteMermaid writes the service graph of the edges as a Mermaid flowchart.
+// Services are given generated node IDs, in sorted order, since Mermaid IDs
+// can't contain most of the characters found in service names.
+func WriteMermaid(w io.Writer, edges []ServiceEdge) error {
+	seen := make(map[string]struct{})
+	for _, e := range edges {
+		seen[e.Parent] = struct{}{}
+		seen[e.Child] = struct{}{}
+	}
+	services := make([]string, 0, len(seen))
+	for s := range seen {
+		services = append(services, s)
+	}
+	sort.Strings(services)
+
+	ids := make(map[string]string, len(services))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, s := range services {
+		ids[s] = fmt.Sprintf("s%d", i)
+		fmt.Fprintf(&b, "  %s[%s]\n", ids[s], mermaidQuote(s))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[e.Parent], mermaidQuote(e.label()), ids[e.Child])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidQuote returns s as a double quoted Mermaid label, double quotes are
+// escaped as entity codes.
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace(s) + `"`
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stats describes the content of a database.
+type Stats struct {
+	// Tables are the tables of the database, sorted by name.
+	Tables []TableStats
+
+	// Size is the size of the database file in bytes, not counting the WAL.
+	Size int64
+
+	// Start is the earliest start time of the stored spans, End the latest
+	// end time. Both are zero when no span is stored.
+	Start time.Time
+	End   time.Time
+}
+
+// TableStats is the number of rows of a table.
+type TableStats struct {
+	Name string
+	Rows int64
+}
+
+// Stats returns the row counts of every table of the database, including the
+// tables of other signals and the ones added by options of the exporter, along
+// with the size of the database and the time range of the spans. Counting the
+// rows reads every table, which takes a while on large databases.
+func (d *DB) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+
+	tables, err := d.tables(ctx)
+	if err != nil {
+		return stats, err
+	}
+	for _, name := range tables {
+		ts := TableStats{Name: name}
+		query := fmt.Sprintf(`SELECT count(*) FROM "%s";`, strings.ReplaceAll(name, `"`, `""`))
+		if err := d.db.QueryRowContext(ctx, query).Scan(&ts.Rows); err != nil {
+			return stats, fmt.Errorf("failed to count rows of table %s: %w", name, err)
+		}
+		stats.Tables = append(stats.Tables, ts)
+	}
+
+	err = d.db.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size();").Scan(&stats.Size)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	var start, end sql.NullInt64
+	if err := d.db.QueryRowContext(ctx, "SELECT min(start_time_ns), max(end_time_ns) FROM traces;").Scan(&start, &end); err != nil {
+		return stats, fmt.Errorf("failed to read time range of traces: %w", err)
+	}
+	if start.Valid {
+		stats.Start = time.Unix(0, start.Int64).UTC()
+		stats.End = time.Unix(0, end.Int64).UTC()
+	}
+
+	return stats, nil
+}
+
+// tables returns the sorted names of the tables of the database, excluding
+// the internal tables of Sqlite.
+func (d *DB) tables(ctx context.Context) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT name FROM sqlite_schema WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' ORDER BY name;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return tables, nil
+}
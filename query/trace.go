@@ -0,0 +1,280 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// spans are read from the spans_denormalized view, which has the resource
+// and scope columns filled in with both the denormalized and normalized
+// schema, and the nanosecond timestamps with either precision.
+const spansQuery = `SELECT
+	span_id,
+	parent_span_id,
+	tracestate,
+	name,
+	kind,
+	start_time_ns,
+	end_time_ns,
+	status_code,
+	status_description,
+	json(attributes),
+	dropped_attributes_count,
+	dropped_events_count,
+	dropped_links_count,
+	json(resource_attributes),
+	resource_dropped_attributes_count,
+	instrumentation_library_name,
+	instrumentation_library_version,
+	json(instrumentation_library_attributes),
+	resource_schema_url,
+	instrumentation_library_schema_url,
+	instrumentation_library_dropped_attributes_count
+FROM spans_denormalized
+WHERE trace_id = ?
+ORDER BY start_time, span_id;`
+
+const eventsQuery = `SELECT span_id, timestamp_ns, name, json(attributes), dropped_attributes_count
+FROM events_ns
+WHERE trace_id = ?
+ORDER BY span_id, event_index;`
+
+const linksQuery = `SELECT parent_span_id, span_id, trace_id, tracestate, json(attributes), dropped_attributes_count
+FROM links
+WHERE parent_trace_id = ?
+ORDER BY parent_span_id, link_index;`
+
+// spanKinds maps the kinds stored in the spans table back to their value.
+var spanKinds = map[string]ptrace.SpanKind{}
+
+func init() {
+	for _, k := range []ptrace.SpanKind{
+		ptrace.SpanKindUnspecified,
+		ptrace.SpanKindInternal,
+		ptrace.SpanKindServer,
+		ptrace.SpanKindClient,
+		ptrace.SpanKindProducer,
+		ptrace.SpanKindConsumer,
+	} {
+		spanKinds[k.String()] = k
+	}
+}
+
+// resourceKey and scopeKey identify the resources and scopes the spans of a
+// trace are grouped under.
+type resourceKey struct {
+	attributes string
+	dropped    uint32
+	schemaURL  string
+}
+
+type scopeKey struct {
+	resource   resourceKey
+	name       string
+	version    string
+	attributes string
+	dropped    uint32
+	schemaURL  string
+}
+
+// GetTrace returns every span of the trace, grouped by resource and scope,
+// along with their events and links. It returns ErrNotFound when no span of
+// the trace is stored.
+func (d *DB) GetTrace(ctx context.Context, traceID pcommon.TraceID) (ptrace.Traces, error) {
+	traces := ptrace.NewTraces()
+	tid := traceID[:]
+
+	rows, err := d.db.QueryContext(ctx, spansQuery, tid)
+	if err != nil {
+		return traces, fmt.Errorf("failed to query spans: %w", err)
+	}
+	defer rows.Close()
+
+	resources := make(map[resourceKey]ptrace.ResourceSpans)
+	scopes := make(map[scopeKey]ptrace.ScopeSpans)
+	spans := make(map[pcommon.SpanID]ptrace.Span)
+	for rows.Next() {
+		var (
+			spanID, parentID                   []byte
+			traceState, name, kind             sql.NullString
+			start, end                         int64
+			statusCode                         int32
+			statusMessage                      sql.NullString
+			attrs                              sql.NullString
+			dropped, droppedEvents, droppedLks sql.NullInt64
+			rattrs                             sql.NullString
+			rdropped                           sql.NullInt64
+			sname, sversion, sattrs            sql.NullString
+			rschema, sschema                   sql.NullString
+			sdropped                           sql.NullInt64
+		)
+		err := rows.Scan(
+			&spanID, &parentID, &traceState, &name, &kind, &start, &end,
+			&statusCode, &statusMessage, &attrs, &dropped, &droppedEvents, &droppedLks,
+			&rattrs, &rdropped, &sname, &sversion, &sattrs,
+			&rschema, &sschema, &sdropped,
+		)
+		if err != nil {
+			return traces, fmt.Errorf("failed to scan span: %w", err)
+		}
+
+		rk := resourceKey{attributes: rattrs.String, dropped: uint32(rdropped.Int64), schemaURL: rschema.String}
+		rs, ok := resources[rk]
+		if !ok {
+			rs = traces.ResourceSpans().AppendEmpty()
+			if err := decodeMap(rs.Resource().Attributes(), rattrs.String); err != nil {
+				return traces, fmt.Errorf("failed to decode resource attributes: %w", err)
+			}
+			rs.Resource().SetDroppedAttributesCount(rk.dropped)
+			rs.SetSchemaUrl(rk.schemaURL)
+			resources[rk] = rs
+		}
+
+		sk := scopeKey{
+			resource:   rk,
+			name:       sname.String,
+			version:    sversion.String,
+			attributes: sattrs.String,
+			dropped:    uint32(sdropped.Int64),
+			schemaURL:  sschema.String,
+		}
+		ss, ok := scopes[sk]
+		if !ok {
+			ss = rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(sk.name)
+			ss.Scope().SetVersion(sk.version)
+			if err := decodeMap(ss.Scope().Attributes(), sattrs.String); err != nil {
+				return traces, fmt.Errorf("failed to decode scope attributes: %w", err)
+			}
+			ss.Scope().SetDroppedAttributesCount(sk.dropped)
+			ss.SetSchemaUrl(sk.schemaURL)
+			scopes[sk] = ss
+		}
+
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(traceID)
+		span.SetSpanID(spanIDFromBytes(spanID))
+		span.SetParentSpanID(spanIDFromBytes(parentID))
+		span.TraceState().FromRaw(traceState.String)
+		span.SetName(name.String)
+		span.SetKind(spanKinds[kind.String])
+		span.SetStartTimestamp(pcommon.Timestamp(start))
+		span.SetEndTimestamp(pcommon.Timestamp(end))
+		span.Status().SetCode(ptrace.StatusCode(statusCode))
+		span.Status().SetMessage(statusMessage.String)
+		if err := decodeMap(span.Attributes(), attrs.String); err != nil {
+			return traces, fmt.Errorf("failed to decode span attributes: %w", err)
+		}
+		span.SetDroppedAttributesCount(uint32(dropped.Int64))
+		span.SetDroppedEventsCount(uint32(droppedEvents.Int64))
+		span.SetDroppedLinksCount(uint32(droppedLks.Int64))
+		spans[span.SpanID()] = span
+	}
+	if err := rows.Err(); err != nil {
+		return traces, fmt.Errorf("failed to query spans: %w", err)
+	}
+
+	if len(spans) == 0 {
+		return traces, ErrNotFound
+	}
+
+	if err := d.getEvents(ctx, tid, spans); err != nil {
+		return traces, err
+	}
+	if err := d.getLinks(ctx, tid, spans); err != nil {
+		return traces, err
+	}
+
+	return traces, nil
+}
+
+func (d *DB) getEvents(ctx context.Context, traceID []byte, spans map[pcommon.SpanID]ptrace.Span) error {
+	rows, err := d.db.QueryContext(ctx, eventsQuery, traceID)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			spanID    []byte
+			timestamp int64
+			name      sql.NullString
+			attrs     sql.NullString
+			dropped   sql.NullInt64
+		)
+		if err := rows.Scan(&spanID, &timestamp, &name, &attrs, &dropped); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		span, ok := spans[spanIDFromBytes(spanID)]
+		if !ok {
+			continue
+		}
+		event := span.Events().AppendEmpty()
+		event.SetTimestamp(pcommon.Timestamp(timestamp))
+		event.SetName(name.String)
+		if err := decodeMap(event.Attributes(), attrs.String); err != nil {
+			return fmt.Errorf("failed to decode event attributes: %w", err)
+		}
+		event.SetDroppedAttributesCount(uint32(dropped.Int64))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) getLinks(ctx context.Context, traceID []byte, spans map[pcommon.SpanID]ptrace.Span) error {
+	rows, err := d.db.QueryContext(ctx, linksQuery, traceID)
+	if err != nil {
+		return fmt.Errorf("failed to query links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			parentID, spanID, linkTraceID []byte
+			traceState                    sql.NullString
+			attrs                         sql.NullString
+			dropped                       sql.NullInt64
+		)
+		if err := rows.Scan(&parentID, &spanID, &linkTraceID, &traceState, &attrs, &dropped); err != nil {
+			return fmt.Errorf("failed to scan link: %w", err)
+		}
+
+		span, ok := spans[spanIDFromBytes(parentID)]
+		if !ok {
+			continue
+		}
+		link := span.Links().AppendEmpty()
+		link.SetTraceID(traceIDFromBytes(linkTraceID))
+		link.SetSpanID(spanIDFromBytes(spanID))
+		link.TraceState().FromRaw(traceState.String)
+		if err := decodeMap(link.Attributes(), attrs.String); err != nil {
+			return fmt.Errorf("failed to decode link attributes: %w", err)
+		}
+		link.SetDroppedAttributesCount(uint32(dropped.Int64))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query links: %w", err)
+	}
+	return nil
+}
+
+func spanIDFromBytes(b []byte) pcommon.SpanID {
+	var id pcommon.SpanID
+	copy(id[:], b)
+	return id
+}
+
+func traceIDFromBytes(b []byte) pcommon.TraceID {
+	var id pcommon.TraceID
+	copy(id[:], b)
+	return id
+}
@@ -0,0 +1,259 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pruneBatchSize is the number of spans deleted at once when the database is
+// over its maximum size.
+const pruneBatchSize = 1000
+
+// metricsTables are the tables holding metric data points, pruned on their
+// time column.
+var metricsTables = []string{
+	"metrics_gauge",
+	"metrics_sum",
+	"metrics_histogram",
+	"metrics_exponential_histogram",
+	"metrics_summary",
+}
+
+// prunedTable is a table pruned by retention.
+type prunedTable struct {
+	name string
+
+	// time is the expression of the microsecond precision unix timestamp
+	// rows are pruned on.
+	time string
+}
+
+// prunedTables are the tables holding spans, log records and metric data
+// points. Events, links and indexed attributes are deleted along with their
+// span.
+var prunedTables = func() []prunedTable {
+	tables := []prunedTable{
+		{name: "spans", time: "start_time"},
+		// records without a timestamp are pruned based on when they were
+		// observed instead.
+		{name: "logs", time: "coalesce(nullif(timestamp, 0), observed_timestamp)"},
+	}
+	for _, table := range metricsTables {
+		tables = append(tables, prunedTable{name: table, time: "time"})
+	}
+	return tables
+}()
+
+// startRetention starts the background goroutine pruning the database every
+// check interval. It is a no-op when retention isn't enabled.
+func (e *sqliteExporter) startRetention() {
+	if !e.retention.enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopRetention = cancel
+	e.retentionDone = make(chan struct{})
+
+	go func() {
+		defer close(e.retentionDone)
+
+		ticker := time.NewTicker(e.retention.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := e.prune(ctx, time.Now()); err != nil && ctx.Err() == nil {
+					e.logger.Warn("failed to prune the database", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// shutdownRetention stops the background goroutine, if any, and waits for it
+// to exit.
+func (e *sqliteExporter) shutdownRetention() {
+	if e.stopRetention == nil {
+		return
+	}
+	e.stopRetention()
+	<-e.retentionDone
+	e.stopRetention = nil
+}
+
+// prune deletes the data older than the maximum age, then the oldest data
+// until the database is under its maximum size.
+func (e *sqliteExporter) prune(ctx context.Context, now time.Time) error {
+	if e.retention.MaxAge > 0 {
+		if err := e.pruneOlderThan(ctx, unixMicro(now.Add(-e.retention.MaxAge))); err != nil {
+			return err
+		}
+	}
+
+	if e.retention.MaxSizeBytes > 0 {
+		if err := e.pruneToSize(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := e.withTx(ctx, func(tx *sql.Tx) error { return deleteOrphans(ctx, tx) }); err != nil {
+		return err
+	}
+
+	if e.retention.IncrementalVacuum {
+		if _, err := e.db.ExecContext(ctx, "PRAGMA incremental_vacuum;"); err != nil {
+			return fmt.Errorf("failed to run incremental vacuum: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// pruneOlderThan deletes the spans, logs and metric data points older than
+// the cutoff, a microsecond precision unix timestamp.
+func (e *sqliteExporter) pruneOlderThan(ctx context.Context, cutoff int64) error {
+	return e.withTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range prunedTables {
+			if _, err := deleteRows(ctx, tx, table, table.time+" < ?", cutoff); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pruneToSize deletes the oldest data in batches until the pages in use by
+// the database are under the maximum size. Each batch is taken from the table
+// holding the oldest row, whether it is a span, a log record or a metric data
+// point. Freed pages are reused by new writes, but the file itself only
+// shrinks with a vacuum.
+func (e *sqliteExporter) pruneToSize(ctx context.Context) error {
+	for {
+		size, err := usedSize(ctx, e.db)
+		if err != nil {
+			return err
+		}
+		if size <= e.retention.MaxSizeBytes {
+			return nil
+		}
+
+		var deleted int64
+		err = e.withTx(ctx, func(tx *sql.Tx) error {
+			table, ok, err := oldestTable(ctx, tx)
+			if err != nil || !ok {
+				return err
+			}
+
+			var cutoff int64
+			err = tx.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT max(t) FROM (SELECT %s AS t FROM %s ORDER BY t LIMIT ?);", table.time, table.name),
+				pruneBatchSize,
+			).Scan(&cutoff)
+			if err != nil {
+				return fmt.Errorf("failed to find oldest rows of %s: %w", table.name, err)
+			}
+
+			deleted, err = deleteRows(ctx, tx, table, table.time+" <= ?", cutoff)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// nothing left to delete, the rest of the database is the schema.
+		if deleted == 0 {
+			return nil
+		}
+	}
+}
+
+// oldestTable returns the table holding the oldest row, or false when all the
+// tables are empty.
+func oldestTable(ctx context.Context, tx *sql.Tx) (prunedTable, bool, error) {
+	var (
+		oldest     prunedTable
+		oldestTime sql.NullInt64
+	)
+	for _, table := range prunedTables {
+		var t sql.NullInt64
+		err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT min(%s) FROM %s;", table.time, table.name)).Scan(&t)
+		if err != nil {
+			return prunedTable{}, false, fmt.Errorf("failed to find oldest row of %s: %w", table.name, err)
+		}
+		if t.Valid && (!oldestTime.Valid || t.Int64 < oldestTime.Int64) {
+			oldest, oldestTime = table, t
+		}
+	}
+	return oldest, oldestTime.Valid, nil
+}
+
+// deleteRows deletes the rows of a table matching the condition, and returns
+// the number of rows deleted. Spans are deleted along with their events,
+// links and indexed attributes.
+func deleteRows(ctx context.Context, tx *sql.Tx, table prunedTable, cond string, args ...any) (int64, error) {
+	if table.name == "spans" {
+		return deleteSpans(ctx, tx, cond, args...)
+	}
+
+	res, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s;", table.name, cond), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete from %s: %w", table.name, err)
+	}
+	return res.RowsAffected()
+}
+
+// deleteSpans deletes the spans matching the condition along with their
+// events, links and indexed attributes, and returns the number of spans
+// deleted. The span trees, self times, critical paths and summaries of their
+// traces are rebuilt from the spans left.
+func deleteSpans(ctx context.Context, tx *sql.Tx, cond string, args ...any) (int64, error) {
+	traceIDs, err := matchingTraceIDs(ctx, tx, cond, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list traces of deleted spans: %w", err)
+	}
+
+	spans := "SELECT trace_id, span_id FROM spans WHERE " + cond
+	if _, err := tx.ExecContext(ctx, "DELETE FROM events WHERE (trace_id, span_id) IN ("+spans+");", args...); err != nil {
+		return 0, fmt.Errorf("failed to delete events: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM links WHERE (parent_trace_id, parent_span_id) IN ("+spans+");", args...); err != nil {
+		return 0, fmt.Errorf("failed to delete links: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM span_attributes WHERE (trace_id, span_id) IN ("+spans+");", args...); err != nil {
+		return 0, fmt.Errorf("failed to delete span attributes: %w", err)
+	}
+	// the ancestry of the spans left may go through deleted spans.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM span_ancestry WHERE trace_id IN (SELECT trace_id FROM spans WHERE "+cond+");", args...); err != nil {
+		return 0, fmt.Errorf("failed to delete span ancestry: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM spans WHERE "+cond+";", args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete spans: %w", err)
+	}
+	if err := updateTraces(ctx, tx, traceIDs); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// usedSize returns the number of bytes used by the database pages, excluding
+// the free pages.
+func usedSize(ctx context.Context, db *sql.DB) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(ctx,
+		"SELECT (page_count - freelist_count) * page_size FROM pragma_page_count(), pragma_freelist_count(), pragma_page_size();",
+	).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute database size: %w", err)
+	}
+	return size, nil
+}
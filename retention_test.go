@@ -0,0 +1,160 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func newRetentionTestExporter(t *testing.T, retention RetentionConfig) (*sqliteExporter, *sql.DB) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Retention = retention
+	ex, err := newSqliteExporterWithDB(db, cfg)
+	require.NoError(t, err)
+	return ex, db
+}
+
+// oldTraces returns a batch of spans that started at the given time.
+func oldTraces(seed, n int, start time.Time) ptrace.Traces {
+	traces := benchmarkTraces(seed, n)
+	spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := 0; i < spans.Len(); i++ {
+		spans.At(i).SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+		spans.At(i).SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(time.Millisecond)))
+	}
+	return traces
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	var total int
+	err := db.QueryRow(fmt.Sprintf("select count(1) from %s;", table)).Scan(&total)
+	require.NoError(t, err)
+	return total
+}
+
+func Test_ExporterPruneMaxAge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+	require.NoError(t, ex.ConsumeTraces(ctx, oldTraces(0, 5, now.Add(-2*time.Hour))))
+	require.NoError(t, ex.ConsumeTraces(ctx, oldTraces(1, 3, now.Add(-time.Minute))))
+
+	require.NoError(t, ex.prune(ctx, now))
+
+	assert.Equal(t, 3, countRows(t, db, "spans"))
+	assert.Equal(t, 6, countRows(t, db, "events"))
+	assert.Equal(t, 3, countRows(t, db, "links"))
+}
+
+func Test_ExporterPruneMaxSize(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxSizeBytes: 1, CheckInterval: time.Minute})
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 10)))
+	require.NoError(t, ex.ConsumeLogs(ctx, oldLogs(now)))
+	require.NoError(t, ex.ConsumeMetrics(ctx, oldMetrics(now)))
+
+	// the schema alone is over the limit, every span, log record and data
+	// point gets deleted and pruning stops once there is nothing left to
+	// delete.
+	require.NoError(t, ex.prune(ctx, now))
+
+	for _, table := range []string{"spans", "events", "links", "logs", "metrics_gauge"} {
+		assert.Equal(t, 0, countRows(t, db, table), table)
+	}
+}
+
+func Test_oldestTable(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, _ := newRetentionTestExporter(t, RetentionConfig{})
+	require.NoError(t, ex.ConsumeTraces(ctx, oldTraces(0, 1, now.Add(-time.Hour))))
+	require.NoError(t, ex.ConsumeMetrics(ctx, oldMetrics(now.Add(-time.Minute))))
+
+	oldest := func() string {
+		var name string
+		require.NoError(t, ex.withTx(ctx, func(tx *sql.Tx) error {
+			table, ok, err := oldestTable(ctx, tx)
+			if ok {
+				name = table.name
+			}
+			return err
+		}))
+		return name
+	}
+	assert.Equal(t, "spans", oldest())
+
+	// log records without a timestamp are aged on their observed timestamp
+	require.NoError(t, ex.ConsumeLogs(ctx, oldLogs(now.Add(-2*time.Hour))))
+	assert.Equal(t, "logs", oldest())
+}
+
+// oldLogs returns a single log record without a timestamp, observed at the
+// given time.
+func oldLogs(observed time.Time) plog.Logs {
+	logs := plog.NewLogs()
+	lr := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.SetObservedTimestamp(pcommon.NewTimestampFromTime(observed))
+	lr.Body().SetStr("old")
+	return logs
+}
+
+// oldMetrics returns a single gauge data point at the given time.
+func oldMetrics(ts time.Time) pmetric.Metrics {
+	metrics := pmetric.NewMetrics()
+	m := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("old")
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	dp.SetIntValue(1)
+	return metrics
+}
+
+func Test_ExporterRetentionBackground(t *testing.T) {
+	ctx := context.Background()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: 10 * time.Millisecond})
+	require.NoError(t, ex.ConsumeTraces(ctx, oldTraces(0, 5, time.Now().Add(-2*time.Hour))))
+
+	require.NoError(t, ex.Start(ctx, componenttest.NewNopHost()))
+	assert.Eventually(t, func() bool {
+		var total int
+		err := db.QueryRow("select count(1) from spans;").Scan(&total)
+		return err == nil && total == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, ex.Shutdown(ctx))
+}
+
+func Test_NewSqliteSDKTraceExporterRetention(t *testing.T) {
+	ctx := context.Background()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = filepath.Join(t.TempDir(), "sdk.db")
+	cfg.Retention.MaxAge = time.Hour
+
+	// the SDK never calls Start, retention runs as soon as the exporter is
+	// created.
+	exp, err := NewSqliteSDKTraceExporter(cfg)
+	require.NoError(t, err)
+	assert.NotNil(t, exp.(*sqliteExporter).stopRetention)
+	require.NoError(t, exp.Shutdown(ctx))
+}
@@ -0,0 +1,263 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// rollupColumns are the columns of the spans table used to maintain the
+// rollups.
+var rollupColumns = []string{"rollup_pending"}
+
+const (
+	selectPendingRollupsQ = `SELECT coalesce(__service_name, ''), name, kind, status_code, start_time, coalesce(__duration_ns, __duration * 1000)
+FROM spans
+WHERE rollup_pending IS NOT NULL;`
+	clearPendingRollupsQ = `UPDATE spans SET rollup_pending = NULL WHERE rollup_pending IS NOT NULL;`
+	selectRollupQ        = `SELECT request_count, error_count, duration_sum, histogram_type, explicit_bounds, scale, zero_count, bucket_offset, bucket_counts
+FROM span_rollups
+WHERE __service_name = ? AND name = ? AND kind = ? AND status_code = ? AND bucket_width = ? AND time_bucket = ?;`
+	upsertRollupQ = `INSERT OR REPLACE INTO span_rollups (__service_name, name, kind, status_code, bucket_width, time_bucket, request_count, error_count, duration_sum, histogram_type, explicit_bounds, scale, zero_count, bucket_offset, bucket_counts)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+)
+
+// rollups maintains the span_rollups table.
+type rollups struct {
+	// width is the width of the time buckets, in microseconds.
+	width int64
+
+	// histogram is the layout of the histograms of new rollups.
+	histogram rollupHistogram
+}
+
+// rollupHistogram is the layout of a duration histogram.
+type rollupHistogram struct {
+	typ string
+
+	// bounds are the upper bounds of the explicit buckets, in nanoseconds.
+	bounds []int64
+
+	// scale is the scale of the exponential buckets.
+	scale int32
+}
+
+// rollupKey identifies a row of the span_rollups table, along with the
+// bucket width.
+type rollupKey struct {
+	service, name, kind string
+	statusCode          int64
+	timeBucket          int64
+}
+
+// newRollups returns the rollups of the config, or nil if they aren't
+// enabled.
+func newRollups(cfg RollupsConfig) *rollups {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	r := &rollups{
+		width:     cfg.BucketWidth.Microseconds(),
+		histogram: rollupHistogram{typ: cfg.Histogram.Type, scale: cfg.Histogram.Scale},
+	}
+	if r.histogram.typ == RollupHistogramExplicit {
+		for _, b := range cfg.Histogram.Boundaries {
+			r.histogram.bounds = append(r.histogram.bounds, b.Nanoseconds())
+		}
+	}
+	return r
+}
+
+// update adds the spans written since the last update to their rollup, and
+// marks them as rolled up. A rollup that already exists keeps its histogram
+// layout even if the config changed since it was created.
+func (r *rollups) update(ctx context.Context, tx *sql.Tx) error {
+	durations, err := r.pending(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to read spans to roll up: %w", err)
+	}
+	if len(durations) == 0 {
+		return nil
+	}
+
+	sel, err := tx.PrepareContext(ctx, selectRollupQ)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rollup select stmt: %w", err)
+	}
+	defer sel.Close()
+	upsert, err := tx.PrepareContext(ctx, upsertRollupQ)
+	if err != nil {
+		return fmt.Errorf("failed to prepare rollup insert stmt: %w", err)
+	}
+	defer upsert.Close()
+
+	for key, ds := range durations {
+		row, err := r.read(ctx, sel, key)
+		if err != nil {
+			return fmt.Errorf("failed to read rollup: %w", err)
+		}
+		for _, d := range ds {
+			row.add(key.statusCode, d)
+		}
+		if err := r.write(ctx, upsert, key, row); err != nil {
+			return fmt.Errorf("failed to write rollup: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, clearPendingRollupsQ); err != nil {
+		return fmt.Errorf("failed to mark spans as rolled up: %w", err)
+	}
+	return nil
+}
+
+// pending returns the durations of the spans not rolled up yet, in
+// nanoseconds, by rollup.
+func (r *rollups) pending(ctx context.Context, tx *sql.Tx) (map[rollupKey][]int64, error) {
+	rows, err := tx.QueryContext(ctx, selectPendingRollupsQ)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	durations := make(map[rollupKey][]int64)
+	for rows.Next() {
+		var key rollupKey
+		var start, d int64
+		if err := rows.Scan(&key.service, &key.name, &key.kind, &key.statusCode, &start, &d); err != nil {
+			return nil, err
+		}
+		key.timeBucket = start - mod(start, r.width)
+		durations[key] = append(durations[key], d)
+	}
+	return durations, rows.Err()
+}
+
+// rollupRow holds the aggregates of a rollup.
+type rollupRow struct {
+	requests, errors, durationSum int64
+
+	histogram rollupHistogram
+	zeroCount int64
+	offset    int64
+	counts    []int64
+}
+
+// read returns the stored rollup of the key, or an empty one with the
+// configured histogram.
+func (r *rollups) read(ctx context.Context, sel *sql.Stmt, key rollupKey) (*rollupRow, error) {
+	row := &rollupRow{}
+	var bounds, counts sql.NullString
+	var scale, zeroCount, offset sql.NullInt64
+	err := sel.QueryRowContext(ctx, key.service, key.name, key.kind, key.statusCode, r.width, key.timeBucket).Scan(
+		&row.requests, &row.errors, &row.durationSum, &row.histogram.typ, &bounds, &scale, &zeroCount, &offset, &counts,
+	)
+	if err == sql.ErrNoRows {
+		row.histogram = r.histogram
+		row.counts = []int64{}
+		if row.histogram.typ == RollupHistogramExplicit {
+			row.counts = make([]int64, len(row.histogram.bounds)+1)
+		}
+		return row, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bounds.Valid {
+		if err := json.Unmarshal([]byte(bounds.String), &row.histogram.bounds); err != nil {
+			return nil, fmt.Errorf("failed to decode explicit bounds: %w", err)
+		}
+	}
+	if err := json.Unmarshal([]byte(counts.String), &row.counts); err != nil {
+		return nil, fmt.Errorf("failed to decode bucket counts: %w", err)
+	}
+	row.histogram.scale = int32(scale.Int64)
+	row.zeroCount = zeroCount.Int64
+	row.offset = offset.Int64
+	return row, nil
+}
+
+func (r *rollups) write(ctx context.Context, upsert *sql.Stmt, key rollupKey, row *rollupRow) error {
+	counts, err := json.Marshal(row.counts)
+	if err != nil {
+		return err
+	}
+
+	var bounds, scale, zeroCount, offset any
+	switch row.histogram.typ {
+	case RollupHistogramExplicit:
+		b, err := json.Marshal(row.histogram.bounds)
+		if err != nil {
+			return err
+		}
+		bounds = string(b)
+	case RollupHistogramExponential:
+		scale, zeroCount, offset = row.histogram.scale, row.zeroCount, row.offset
+	}
+
+	_, err = upsert.ExecContext(ctx,
+		key.service, key.name, key.kind, key.statusCode, r.width, key.timeBucket,
+		row.requests, row.errors, row.durationSum,
+		row.histogram.typ, bounds, scale, zeroCount, offset, string(counts),
+	)
+	return err
+}
+
+// add counts a span with the status code and duration, in nanoseconds.
+func (row *rollupRow) add(statusCode, d int64) {
+	row.requests++
+	if statusCode == int64(ptrace.StatusCodeError) {
+		row.errors++
+	}
+	row.durationSum += d
+
+	switch row.histogram.typ {
+	case RollupHistogramExplicit:
+		// bucket i counts the durations up to bounds[i], the last one the
+		// durations over every bound.
+		i := sort.Search(len(row.histogram.bounds), func(i int) bool { return row.histogram.bounds[i] >= d })
+		if i < len(row.counts) {
+			row.counts[i]++
+		}
+	case RollupHistogramExponential:
+		if d <= 0 {
+			row.zeroCount++
+			return
+		}
+		idx := exponentialIndex(d, row.histogram.scale)
+		switch {
+		case len(row.counts) == 0:
+			row.offset = idx
+			row.counts = []int64{0}
+		case idx < row.offset:
+			row.counts = append(make([]int64, row.offset-idx), row.counts...)
+			row.offset = idx
+		case idx >= row.offset+int64(len(row.counts)):
+			row.counts = append(row.counts, make([]int64, idx-row.offset-int64(len(row.counts))+1)...)
+		}
+		row.counts[idx-row.offset]++
+	}
+}
+
+// exponentialIndex returns the index of the exponential bucket of the value
+// at the scale, bucket i counts the values greater than base^i and up to
+// base^(i+1), with base = 2^(2^-scale).
+func exponentialIndex(v int64, scale int32) int64 {
+	return int64(math.Ceil(math.Log2(float64(v))*math.Ldexp(1, int(scale)))) - 1
+}
+
+// mod returns the non-negative remainder of a divided by b.
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
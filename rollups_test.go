@@ -0,0 +1,188 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func Test_exponentialIndex(t *testing.T) {
+	tests := []struct {
+		v     int64
+		scale int32
+		want  int64
+	}{
+		{v: 1, scale: 0, want: -1},
+		{v: 2, scale: 0, want: 0},
+		{v: 3, scale: 0, want: 1},
+		{v: 4, scale: 0, want: 1},
+		{v: 5, scale: 0, want: 2},
+		{v: 4, scale: 1, want: 3},
+		{v: 5, scale: 1, want: 4},
+		{v: 1024, scale: -1, want: 4},
+		{v: 1025, scale: -1, want: 5},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, exponentialIndex(tt.v, tt.scale), "%d at scale %d", tt.v, tt.scale)
+	}
+}
+
+func Test_rollupRowAdd(t *testing.T) {
+	explicit := &rollupRow{
+		histogram: rollupHistogram{typ: RollupHistogramExplicit, bounds: []int64{10, 100}},
+		counts:    make([]int64, 3),
+	}
+	for _, d := range []int64{0, 10, 11, 100, 101, 1000} {
+		explicit.add(0, d)
+	}
+	explicit.add(int64(ptrace.StatusCodeError), 50)
+	assert.Equal(t, &rollupRow{
+		requests:    7,
+		errors:      1,
+		durationSum: 1272,
+		histogram:   explicit.histogram,
+		counts:      []int64{2, 3, 2},
+	}, explicit)
+
+	exponential := &rollupRow{
+		histogram: rollupHistogram{typ: RollupHistogramExponential},
+		counts:    []int64{},
+	}
+	// the buckets grow in both directions from the first one.
+	for _, d := range []int64{5, 0, 2, 16, 5} {
+		exponential.add(0, d)
+	}
+	assert.Equal(t, &rollupRow{
+		requests:    5,
+		durationSum: 28,
+		histogram:   exponential.histogram,
+		zeroCount:   1,
+		offset:      0,
+		counts:      []int64{1, 0, 2, 1},
+	}, exponential)
+}
+
+type rollup struct {
+	Service, Name, Kind      string
+	StatusCode, TimeBucket   int64
+	Requests, Errors, DurSum int64
+	Type, Bounds, Counts     string
+	Scale, ZeroCount, Offset sql.NullInt64
+}
+
+func readRollups(t *testing.T, db *sql.DB) []rollup {
+	rows, err := db.Query(`select __service_name, name, kind, status_code, time_bucket, request_count, error_count, duration_sum,
+histogram_type, coalesce(explicit_bounds, ''), bucket_counts, scale, zero_count, bucket_offset
+from span_rollups order by time_bucket, __service_name, name, status_code;`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var rollups []rollup
+	for rows.Next() {
+		var r rollup
+		require.NoError(t, rows.Scan(
+			&r.Service, &r.Name, &r.Kind, &r.StatusCode, &r.TimeBucket, &r.Requests, &r.Errors, &r.DurSum,
+			&r.Type, &r.Bounds, &r.Counts, &r.Scale, &r.ZeroCount, &r.Offset,
+		))
+		rollups = append(rollups, r)
+	}
+	require.NoError(t, rows.Err())
+	return rollups
+}
+
+func Test_ExporterRollups(t *testing.T) {
+	ctx := context.Background()
+	bucket := time.Unix(1700000040, 0)
+	ms := time.Millisecond
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{})
+	ex.rollups = newRollups(RollupsConfig{
+		Enabled:     true,
+		BucketWidth: time.Minute,
+		Histogram: RollupHistogramConfig{
+			Type:       RollupHistogramExplicit,
+			Boundaries: []time.Duration{10 * ms, 100 * ms},
+		},
+	})
+
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, bucket.Add(time.Second), 5*ms, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 2, 0, bucket.Add(2*time.Second), 50*ms, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 3, 0, bucket.Add(3*time.Second), 500*ms, ptrace.StatusCodeError)))
+	// sending a span again doesn't count it twice.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, bucket.Add(time.Second), 5*ms, ptrace.StatusCodeUnset)))
+	// the next bucket starts a minute later.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 4, 0, bucket.Add(time.Minute), 20*ms, ptrace.StatusCodeUnset)))
+
+	start := unixMicro(time.Unix(1700000040, 0).Truncate(time.Minute))
+	assert.Equal(t, []rollup{
+		{
+			Service: "frontend", Name: "frontend-1", Kind: "Unspecified", TimeBucket: start,
+			Requests: 1, DurSum: int64(5 * ms),
+			Type: RollupHistogramExplicit, Bounds: "[10000000,100000000]", Counts: "[1,0,0]",
+		},
+		{
+			Service: "frontend", Name: "frontend-2", Kind: "Unspecified", TimeBucket: start,
+			Requests: 1, DurSum: int64(50 * ms),
+			Type: RollupHistogramExplicit, Bounds: "[10000000,100000000]", Counts: "[0,1,0]",
+		},
+		{
+			Service: "frontend", Name: "frontend-3", Kind: "Unspecified", StatusCode: 2, TimeBucket: start,
+			Requests: 1, Errors: 1, DurSum: int64(500 * ms),
+			Type: RollupHistogramExplicit, Bounds: "[10000000,100000000]", Counts: "[0,0,1]",
+		},
+		{
+			Service: "frontend", Name: "frontend-4", Kind: "Unspecified", TimeBucket: start + time.Minute.Microseconds(),
+			Requests: 1, DurSum: int64(20 * ms),
+			Type: RollupHistogramExplicit, Bounds: "[10000000,100000000]", Counts: "[0,1,0]",
+		},
+	}, readRollups(t, db))
+	assert.Equal(t, 0, countRows(t, db, "spans where rollup_pending is not null"))
+}
+
+func Test_ExporterRollupsExponential(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000040, 0).Truncate(time.Minute)
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{})
+	ex.rollups = newRollups(RollupsConfig{
+		Enabled:     true,
+		BucketWidth: time.Minute,
+		Histogram:   RollupHistogramConfig{Type: RollupHistogramExponential, Scale: 0},
+	})
+
+	// spans of the same name in a single rollup, over several batches. The
+	// durations are whole microseconds with the default timestamp precision.
+	for _, us := range []int{0, 3, 4, 16} {
+		traces := summarySpan("frontend", byte(us+1), 0, start, time.Duration(us)*time.Microsecond, ptrace.StatusCodeUnset)
+		traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetName("GET /")
+		require.NoError(t, ex.ConsumeTraces(ctx, traces))
+	}
+
+	assert.Equal(t, []rollup{
+		{
+			Service: "frontend", Name: "GET /", Kind: "Unspecified", TimeBucket: unixMicro(start),
+			Requests: 4, DurSum: 23000,
+			Type: RollupHistogramExponential, Counts: "[2,0,1]",
+			Scale: sql.NullInt64{Valid: true}, ZeroCount: sql.NullInt64{Int64: 1, Valid: true}, Offset: sql.NullInt64{Int64: 11, Valid: true},
+		},
+	}, readRollups(t, db))
+}
+
+func Test_ExporterPruneRollups(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+	ex.rollups = newRollups(RollupsConfig{Enabled: true, BucketWidth: time.Minute, Histogram: defaultRollupsConfig().Histogram})
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, now.Add(-2*time.Hour), time.Second, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.prune(ctx, now))
+
+	assert.Equal(t, 0, countRows(t, db, "spans"))
+	assert.Len(t, readRollups(t, db), 1)
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// serviceGraphColumns are the columns of the spans table used to maintain the
+// service graph.
+var serviceGraphColumns = []string{"service_edge_pending"}
+
+// insertServiceEdgesQ counts the calls of the pending spans of a trace whose
+// parent is stored and belongs to another service, adding them to the calls
+// already counted in their time bucket.
+const insertServiceEdgesQ = `INSERT INTO service_edges (parent_service, child_service, bucket_width, time_bucket, call_count, error_count, duration_sum)
+SELECT
+	parent.__service_name,
+	child.__service_name,
+	?2,
+	child.start_time - (child.start_time % ?2),
+	count(*),
+	count(*) FILTER (WHERE child.status_code = 2),
+	sum(coalesce(child.__duration_ns, child.__duration * 1000))
+FROM spans AS child
+JOIN spans AS parent ON parent.trace_id = child.trace_id AND parent.span_id = child.parent_span_id
+WHERE child.trace_id = ?1 AND child.service_edge_pending IS NOT NULL AND parent.__service_name != child.__service_name
+GROUP BY 1, 2, 3, 4
+ON CONFLICT (parent_service, child_service, bucket_width, time_bucket) DO UPDATE SET
+	call_count = call_count + excluded.call_count,
+	error_count = error_count + excluded.error_count,
+	duration_sum = duration_sum + excluded.duration_sum;`
+
+// clearServiceEdgesQ marks the pending spans of a trace whose parent is
+// stored as counted, including the ones calling their own service.
+const clearServiceEdgesQ = `UPDATE spans SET service_edge_pending = NULL
+WHERE trace_id = ?1 AND service_edge_pending IS NOT NULL
+	AND parent_span_id IN (SELECT parent.span_id FROM spans AS parent WHERE parent.trace_id = ?1);`
+
+// serviceGraph maintains the service_edges table.
+type serviceGraph struct {
+	// width is the width of the time buckets, in microseconds.
+	width int64
+}
+
+// newServiceGraph returns the service graph of the config, or nil if it isn't
+// enabled.
+func newServiceGraph(cfg ServiceGraphConfig) *serviceGraph {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &serviceGraph{width: cfg.BucketWidth.Microseconds()}
+}
+
+// update counts the calls of the spans of the traces whose parent is stored
+// and weren't counted yet, so a call is counted once both of its spans are
+// stored, whichever arrives first.
+func (g *serviceGraph) update(ctx context.Context, tx *sql.Tx, traceIDs map[pcommon.TraceID]struct{}) error {
+	if len(traceIDs) == 0 {
+		return nil
+	}
+
+	insert, err := tx.PrepareContext(ctx, insertServiceEdgesQ)
+	if err != nil {
+		return fmt.Errorf("failed to prepare service edges insert stmt: %w", err)
+	}
+	defer insert.Close()
+	clear, err := tx.PrepareContext(ctx, clearServiceEdgesQ)
+	if err != nil {
+		return fmt.Errorf("failed to prepare service edges update stmt: %w", err)
+	}
+	defer clear.Close()
+
+	for id := range traceIDs {
+		traceidraw := [16]byte(id)
+		if _, err := insert.ExecContext(ctx, traceidraw[:], g.width); err != nil {
+			return fmt.Errorf("failed to update service edges of trace %s: %w", id, err)
+		}
+		if _, err := clear.ExecContext(ctx, traceidraw[:]); err != nil {
+			return fmt.Errorf("failed to update service edges of trace %s: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type serviceEdge struct {
+	Parent, Child              string
+	TimeBucket                 int64
+	Calls, Errors, DurationSum int64
+}
+
+func readServiceEdges(t *testing.T, db *sql.DB) []serviceEdge {
+	rows, err := db.Query(`select parent_service, child_service, time_bucket, call_count, error_count, duration_sum
+from service_edges order by time_bucket, parent_service, child_service;`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var edges []serviceEdge
+	for rows.Next() {
+		var e serviceEdge
+		require.NoError(t, rows.Scan(&e.Parent, &e.Child, &e.TimeBucket, &e.Calls, &e.Errors, &e.DurationSum))
+		edges = append(edges, e)
+	}
+	require.NoError(t, rows.Err())
+	return edges
+}
+
+func Test_ExporterServiceGraph(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000040, 0)
+	ms := time.Millisecond
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{})
+	ex.serviceGraph = newServiceGraph(ServiceGraphConfig{Enabled: true, BucketWidth: time.Minute})
+
+	// the children arrive before their parent, in separate batches, and one
+	// of them twice.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 3, 2, start.Add(20*ms), 50*ms, ptrace.StatusCodeError)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 2, 1, start.Add(10*ms), 100*ms, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 4, 1, start.Add(time.Minute), 30*ms, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 3, 2, start.Add(20*ms), 50*ms, ptrace.StatusCodeError)))
+	// a span calling its own service isn't an edge.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 5, 2, start.Add(30*ms), 10*ms, ptrace.StatusCodeUnset)))
+	assert.Equal(t, []serviceEdge{
+		{Parent: "checkout", Child: "payments", TimeBucket: unixMicro(start), Calls: 1, Errors: 1, DurationSum: int64(50 * ms)},
+	}, readServiceEdges(t, db))
+
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, start, time.Second, ptrace.StatusCodeUnset)))
+	assert.Equal(t, []serviceEdge{
+		{Parent: "checkout", Child: "payments", TimeBucket: unixMicro(start), Calls: 1, Errors: 1, DurationSum: int64(50 * ms)},
+		{Parent: "frontend", Child: "checkout", TimeBucket: unixMicro(start), Calls: 1, DurationSum: int64(100 * ms)},
+		{Parent: "frontend", Child: "checkout", TimeBucket: unixMicro(start.Add(time.Minute)), Calls: 1, DurationSum: int64(30 * ms)},
+	}, readServiceEdges(t, db))
+	assert.Equal(t, 0, countRows(t, db, "spans where service_edge_pending is not null"))
+}
+
+func Test_ExporterPruneServiceGraph(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+	ex.serviceGraph = newServiceGraph(ServiceGraphConfig{Enabled: true, BucketWidth: time.Minute})
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, now.Add(-2*time.Hour), time.Second, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 2, 1, now.Add(-2*time.Hour), time.Second, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.prune(ctx, now))
+
+	assert.Equal(t, 0, countRows(t, db, "spans"))
+	assert.Len(t, readServiceEdges(t, db), 1)
+}
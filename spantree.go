@@ -0,0 +1,86 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+// spanTreeColumns are the columns of the spans table set from the other spans
+// of the trace rather than when the span is written.
+var spanTreeColumns = []string{"root_span_id", "depth", "child_count", "self_time_ns"}
+
+// insertSpanAncestryQ adds the rows of the span_ancestry table of a trace
+// that don't exist yet, walking up the parents of every stored span. The
+// ancestry of the spans written before their parent is completed once the
+// parent is stored. It is the same statement as the one backfilling the
+// table in the migrations, for a single trace.
+const insertSpanAncestryQ = `WITH RECURSIVE ancestry(trace_id, ancestor_span_id, descendant_span_id, distance) AS (
+	SELECT trace_id, span_id, span_id, 0 FROM spans WHERE trace_id = ?1
+	UNION ALL
+	SELECT ancestry.trace_id, parent.span_id, ancestry.descendant_span_id, ancestry.distance + 1
+	FROM ancestry
+	JOIN spans AS child ON child.trace_id = ancestry.trace_id AND child.span_id = ancestry.ancestor_span_id
+	JOIN spans AS parent ON parent.trace_id = child.trace_id AND parent.span_id = child.parent_span_id
+	WHERE ancestry.distance < 1000
+)
+INSERT OR IGNORE INTO span_ancestry (trace_id, ancestor_span_id, descendant_span_id, distance)
+SELECT trace_id, ancestor_span_id, descendant_span_id, distance FROM ancestry;`
+
+// updateSpanTreesQ sets the root, depth and number of children of the spans
+// of a trace from the span_ancestry table. Only the spans whose values
+// changed are written.
+const updateSpanTreesQ = `UPDATE spans SET
+	root_span_id = tree.root_span_id,
+	depth = tree.depth,
+	child_count = tree.child_count
+FROM (
+	SELECT
+		s.span_id,
+		root.ancestor_span_id AS root_span_id,
+		root.distance AS depth,
+		(SELECT count(*) FROM spans AS c WHERE c.trace_id = s.trace_id AND c.parent_span_id = s.span_id) AS child_count
+	FROM spans AS s
+	LEFT JOIN span_ancestry AS root ON root.trace_id = s.trace_id AND root.descendant_span_id = s.span_id
+		AND root.ancestor_span_id IN (SELECT r.span_id FROM spans AS r WHERE r.trace_id = s.trace_id AND r.parent_span_id IS NULL)
+	WHERE s.trace_id = ?1
+) AS tree
+WHERE spans.trace_id = ?1 AND spans.span_id = tree.span_id
+	AND (spans.root_span_id IS NOT tree.root_span_id OR spans.depth IS NOT tree.depth OR spans.child_count IS NOT tree.child_count);`
+
+// updateSelfTimesQ sets the self time of the spans of a trace, their duration
+// minus the union of the intervals of their children, clipped to the span.
+// Only the spans whose self time changed are written. It is the same
+// statement as the one backfilling the column in the migrations, for a
+// single trace.
+const updateSelfTimesQ = `WITH bounds AS (
+	SELECT
+		trace_id,
+		span_id,
+		parent_span_id,
+		coalesce(start_time_ns, start_time * 1000) AS start_ns,
+		coalesce(end_time_ns, end_time * 1000) AS end_ns
+	FROM spans
+	WHERE trace_id = ?1
+), children AS (
+	SELECT
+		parent.trace_id,
+		parent.span_id,
+		max(child.start_ns, parent.start_ns) AS start_ns,
+		min(child.end_ns, parent.end_ns) AS end_ns
+	FROM bounds AS parent
+	JOIN bounds AS child ON child.trace_id = parent.trace_id AND child.parent_span_id = parent.span_id AND child.span_id != parent.span_id
+	WHERE max(child.start_ns, parent.start_ns) < min(child.end_ns, parent.end_ns)
+), covered AS (
+	SELECT trace_id, span_id, sum(max(0, end_ns - max(start_ns, coalesce(prev_end_ns, start_ns)))) AS covered_ns
+	FROM (
+		SELECT *, max(end_ns) OVER (
+			PARTITION BY trace_id, span_id ORDER BY start_ns, end_ns
+			ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+		) AS prev_end_ns
+		FROM children
+	)
+	GROUP BY trace_id, span_id
+)
+UPDATE spans SET self_time_ns = self.self_time_ns
+FROM (
+	SELECT bounds.trace_id, bounds.span_id, max(0, bounds.end_ns - bounds.start_ns) - coalesce(covered.covered_ns, 0) AS self_time_ns
+	FROM bounds
+	LEFT JOIN covered ON covered.trace_id = bounds.trace_id AND covered.span_id = bounds.span_id
+) AS self
+WHERE spans.trace_id = ?1 AND spans.span_id = self.span_id AND spans.self_time_ns IS NOT self.self_time_ns;`
@@ -0,0 +1,104 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// readSpanTrees returns the root, depth and child count of every span, keyed
+// by span name.
+func readSpanTrees(t *testing.T, db *sql.DB) map[string]string {
+	rows, err := db.Query("select name, hex(root_span_id), depth, child_count from spans;")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	trees := make(map[string]string)
+	for rows.Next() {
+		var name, root string
+		var depth sql.NullInt64
+		var children int
+		require.NoError(t, rows.Scan(&name, &root, &depth, &children))
+		if depth.Valid {
+			trees[name] = fmt.Sprintf("root=%s depth=%d children=%d", root, depth.Int64, children)
+		} else {
+			trees[name] = fmt.Sprintf("root=%s depth=NULL children=%d", root, children)
+		}
+	}
+	require.NoError(t, rows.Err())
+	return trees
+}
+
+// readDescendants returns the names of the descendants of a span and their
+// distance to it, the span itself excluded.
+func readDescendants(t *testing.T, db *sql.DB, name string) map[string]int {
+	rows, err := db.Query(`select d.name, a.distance from spans as s
+join span_ancestry as a on a.trace_id = s.trace_id and a.ancestor_span_id = s.span_id
+join spans as d on d.trace_id = a.trace_id and d.span_id = a.descendant_span_id
+where s.name = ? and a.distance > 0;`, name)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	descendants := make(map[string]int)
+	for rows.Next() {
+		var d string
+		var distance int
+		require.NoError(t, rows.Scan(&d, &distance))
+		descendants[d] = distance
+	}
+	require.NoError(t, rows.Err())
+	return descendants
+}
+
+func Test_ExporterSpanTreesOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{})
+
+	// the tree is frontend-1 -> checkout-2 -> payments-3, with checkout-4 as
+	// a second child of the root. The deepest span arrives first.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 3, 2, start, time.Millisecond, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 2, 1, start, time.Millisecond, ptrace.StatusCodeUnset)))
+	assert.Equal(t, map[string]string{
+		"payments-3": "root= depth=NULL children=0",
+		"checkout-2": "root= depth=NULL children=1",
+	}, readSpanTrees(t, db))
+	assert.Equal(t, map[string]int{"payments-3": 1}, readDescendants(t, db, "checkout-2"))
+
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, start, time.Second, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 4, 1, start, time.Millisecond, ptrace.StatusCodeUnset)))
+	assert.Equal(t, map[string]string{
+		"frontend-1": "root=0100000000000000 depth=0 children=2",
+		"checkout-2": "root=0100000000000000 depth=1 children=1",
+		"payments-3": "root=0100000000000000 depth=2 children=0",
+		"checkout-4": "root=0100000000000000 depth=1 children=0",
+	}, readSpanTrees(t, db))
+	assert.Equal(t, map[string]int{"checkout-2": 1, "payments-3": 2, "checkout-4": 1}, readDescendants(t, db, "frontend-1"))
+}
+
+func Test_ExporterPruneSpanTrees(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+
+	// the root and its child are old enough to be pruned, the grandchild
+	// isn't.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, now.Add(-3*time.Hour), 3*time.Hour, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 2, 1, now.Add(-2*time.Hour), 2*time.Hour, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 3, 2, now.Add(-time.Minute), time.Second, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.prune(ctx, now))
+
+	assert.Equal(t, map[string]string{
+		"payments-3": "root= depth=NULL children=0",
+	}, readSpanTrees(t, db))
+	assert.Equal(t, 1, countRows(t, db, "span_ancestry"))
+}
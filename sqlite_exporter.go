@@ -13,6 +13,8 @@ import (
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+
 	"go.wperron.io/sqliteexporter/internal/transform"
 )
 
@@ -24,6 +26,61 @@ var _ component.Component = &sqliteExporter{}
 
 type sqliteExporter struct {
 	db *sql.DB
+
+	// rowsPerStatement is the maximum number of rows written by a single
+	// INSERT statement.
+	rowsPerStatement int
+
+	// onConflict is how spans, events and links that already exist in the
+	// database are handled.
+	onConflict string
+
+	// hoisted are the attributes written to their own column of the spans
+	// table.
+	hoisted []HoistedAttribute
+
+	// attributeIndex selects the span attributes written to the
+	// span_attributes table, nil when the table isn't written.
+	attributeIndex *attributeIndex
+
+	// rollups maintains the span_rollups table, nil when the table isn't
+	// written.
+	rollups *rollups
+
+	// serviceGraph maintains the service_edges table, nil when the table
+	// isn't written.
+	serviceGraph *serviceGraph
+
+	// schema is either denormalized, with resources and scopes inlined in
+	// every span, or normalized.
+	schema string
+
+	// jsonFormat is how the attributes of spans, events and links are
+	// stored, text or jsonb.
+	jsonFormat string
+
+	// nanoseconds is whether nanosecond timestamps are written in addition
+	// to the microsecond ones.
+	nanoseconds bool
+
+	// attributeEncoding is how attribute values are encoded in the
+	// attributes JSON, json or otlp_json.
+	attributeEncoding string
+
+	logger *zap.Logger
+
+	// retention controls the background pruning of the database, started by
+	// Start and stopped by Shutdown.
+	retention     RetentionConfig
+	stopRetention context.CancelFunc
+	retentionDone chan struct{}
+
+	// writer writes the batches of every signal, committing those that
+	// arrive together in a single transaction. It is started by Start, until
+	// then every batch is written in its own transaction.
+	writer        *groupWriter
+	maxBatchDelay time.Duration
+	maxBatchRows  int
 }
 
 // DO NOT CHANGE: any modification will not be backwards compatible and
@@ -58,9 +115,18 @@ func (e *sqliteExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadO
 // to Start() function since that context will be cancelled soon and can abort the long-running
 // operation. Create a new context from the context.Background() for long-running operations.
 func (e *sqliteExporter) Start(ctx context.Context, host component.Host) error {
+	e.start()
 	return nil
 }
 
+// start starts the writer and the background pruning of the database. The
+// OpenTelemetry Go SDK never calls Start, so the exporters returned for the
+// SDK are started as soon as they are created instead.
+func (e *sqliteExporter) start() {
+	e.startWriter()
+	e.startRetention()
+}
+
 // Shutdown is invoked during service shutdown. After Shutdown() is called, if the component
 // accepted data in any way, it should not accept it anymore.
 //
@@ -78,110 +144,175 @@ func (e *sqliteExporter) Start(ctx context.Context, host component.Host) error {
 // the same or different configuration may be created and started (this may happen
 // for example if we want to restart the component).
 func (e *sqliteExporter) Shutdown(ctx context.Context) error {
+	e.shutdownRetention()
+	e.shutdownWriter()
 	return e.db.Close()
 }
 
-// TODO(wperron) add instrumentation library (scope) name and version
-const insertSpanQ string = `INSERT INTO spans
-(
-    span_id,
-    trace_id,
-    parent_span_id,
-    tracestate,
-    __service_name,
-    __duration,
-    name,
-    kind,
-    start_time,
-    end_time,
-    status_code,
-    status_description,
-    attributes,
-    dropped_attributes_count,
-    dropped_events_count,
-    dropped_links_count,
-    resource_attributes,
-    resource_dropped_attributes_count,
-    instrumentation_library_name,
-    instrumentation_library_version,
-    instrumentation_library_attributes
-)
-VALUES (
-    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, json(?), ?, ?, ?, json(?), ?, ?, ?, json(?)
-);
-`
-
-const insertEventQ string = `INSERT INTO events
-(
-    span_id,
-    timestamp,
-    name,
-    attributes,
-    dropped_attributes_count
-)
-VALUES (
-    ?, ?, ?, json(?), ?
-);
-`
-
-const insertLinkQ string = `INSERT INTO links
-(
-    parent_span_id,
-    span_id,
-    trace_id,
-    tracestate,
-    attributes,
-    dropped_attributes_count
-)
-VALUES (
-    ?, ?, ?, ?, json(?), ?
-)`
+var spansSpec = insertSpec{
+	table: "spans",
+	columns: []string{
+		"span_id",
+		"trace_id",
+		"parent_span_id",
+		"tracestate",
+		"__service_name",
+		"__duration",
+		"name",
+		"kind",
+		"start_time",
+		"end_time",
+		"status_code",
+		"status_description",
+		"attributes",
+		"dropped_attributes_count",
+		"dropped_events_count",
+		"dropped_links_count",
+		"resource_attributes",
+		"resource_dropped_attributes_count",
+		"instrumentation_library_name",
+		"instrumentation_library_version",
+		"instrumentation_library_attributes",
+		"resource_id",
+		"scope_id",
+		"resource_schema_url",
+		"instrumentation_library_schema_url",
+		"instrumentation_library_dropped_attributes_count",
+		"start_time_ns",
+		"end_time_ns",
+		"__duration_ns",
+	},
+	values: []string{
+		"?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "?", "json(?)", "?", "?", "?", "json(?)", "?", "?", "?", "json(?)", "?", "?", "?", "?", "?", "?", "?", "?",
+	},
+}
 
+var eventsSpec = insertSpec{
+	table: "events",
+	columns: []string{
+		"span_id",
+		"trace_id",
+		"timestamp",
+		"name",
+		"attributes",
+		"dropped_attributes_count",
+		"event_index",
+		"timestamp_ns",
+	},
+	values: []string{
+		"?", "?", "?", "?", "json(?)", "?", "?", "?",
+	},
+}
+
+var linksSpec = insertSpec{
+	table: "links",
+	columns: []string{
+		"parent_span_id",
+		"parent_trace_id",
+		"span_id",
+		"trace_id",
+		"tracestate",
+		"attributes",
+		"dropped_attributes_count",
+		"link_index",
+	},
+	values: []string{
+		"?", "?", "?", "?", "?", "json(?)", "?", "?",
+	},
+}
+
+// ConsumeTraces writes all the spans in a single transaction. If any of them
+// fails to be inserted, the whole batch is rolled back so it can safely be
+// retried.
 func (e *sqliteExporter) ConsumeTraces(ctx context.Context, traces ptrace.Traces) error {
-	tx, err := e.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to start transaction: %w", err)
+	return e.write(ctx, traces.SpanCount(), func(ctx context.Context, tx *sql.Tx) error {
+		return e.insertTraces(ctx, tx, traces)
+	})
+}
+
+func (e *sqliteExporter) insertTraces(ctx context.Context, tx *sql.Tx, traces ptrace.Traces) error {
+	// statements are prepared once per transaction and reused for every row.
+	spec := spansSpec.withHoisted(e.hoisted).withJSONFormat(e.jsonFormat).withConflict(e.onConflict)
+	if e.rollups != nil {
+		spec = spec.withColumn("rollup_pending", "?")
 	}
-	defer tx.Commit()
+	if e.serviceGraph != nil {
+		spec = spec.withColumn("service_edge_pending", "?")
+	}
+	spans := newBatchInserter(tx, spec, e.rowsPerStatement)
+	defer spans.close()
+	// events and links reference their span, buffered spans are written before
+	// any event or link statement is executed.
+	events := newBatchInserter(tx, eventsSpec.withJSONFormat(e.jsonFormat).withConflict(e.onConflict), e.rowsPerStatement).withParent(spans)
+	defer events.close()
+	links := newBatchInserter(tx, linksSpec.withJSONFormat(e.jsonFormat).withConflict(e.onConflict), e.rowsPerStatement).withParent(spans)
+	defer links.close()
+	var attrIndex *batchInserter
+	if e.attributeIndex != nil {
+		attrIndex = newBatchInserter(tx, spanAttributesSpec.withConflict(e.onConflict), e.rowsPerStatement).withParent(spans)
+		defer attrIndex.close()
+	}
+
+	// with the normalized schema, resources and scopes are written once in
+	// their own table and spans only reference them.
+	normalized := e.schema == SchemaNormalized
+	var resources, scopes *batchInserter
+	if normalized {
+		// rows are keyed by their content, a conflict is always a duplicate.
+		resources = newBatchInserter(tx, resourcesSpec.withJSONFormat(e.jsonFormat).withConflict(OnConflictIgnore), e.rowsPerStatement)
+		defer resources.close()
+		scopes = newBatchInserter(tx, scopesSpec.withJSONFormat(e.jsonFormat).withConflict(OnConflictIgnore), e.rowsPerStatement)
+		defer scopes.close()
+		spans.withParent(resources, scopes)
+	}
+
+	// the span trees and summary of every trace with a span in the batch are
+	// updated once all the spans are written.
+	traceIDs := make(map[pcommon.TraceID]struct{})
 
 	for i := 0; i < traces.ResourceSpans().Len(); i++ {
 		resource := traces.ResourceSpans().At(i)
+		svc := serviceName(resource.Resource())
 
-		svc := "unknown"
-		resource.Resource().Attributes().Range(func(k string, v pcommon.Value) bool {
-			if k == "service.name" {
-				svc = v.Str()
-				if svc == "" { // protect against service name being another type for some reason
-					svc = "unknown"
-				}
-				return false
-			}
-			return true
-		})
-
-		rattrs, err := pcommonMapAsJSON(resource.Resource().Attributes())
+		rattrs, err := e.marshalAttributes(resource.Resource().Attributes())
 		if err != nil {
 			return fmt.Errorf("failed to marshal resource attributes as json: %w", err)
 		}
 
+		// values of the inlined resource columns, or of the reference to the
+		// resources table.
+		var rattrsCol, rdroppedCol, rschemaCol, rid any = rattrs, resource.Resource().DroppedAttributesCount(), resource.SchemaUrl(), nil
+		if normalized {
+			id := resourceID(resource.Resource(), resource.SchemaUrl(), rattrs)
+			if err := resources.add(ctx, id, svc, rattrs, resource.Resource().DroppedAttributesCount(), resource.SchemaUrl()); err != nil {
+				return fmt.Errorf("error occured while inserting resource: %w", err)
+			}
+			rattrsCol, rdroppedCol, rschemaCol, rid = nil, nil, nil, id
+		}
+
 		for j := 0; j < resource.ScopeSpans().Len(); j++ {
 			scope := resource.ScopeSpans().At(j)
-			sattrs, err := pcommonMapAsJSON(scope.Scope().Attributes())
+			sattrs, err := e.marshalAttributes(scope.Scope().Attributes())
 			if err != nil {
 				return fmt.Errorf("failed to marshal instrumentation scope attributes as json: %w", err)
 			}
 
+			var snameCol, sversionCol, sattrsCol, sdroppedCol, sschemaCol, sid any = scope.Scope().Name(), scope.Scope().Version(), sattrs, scope.Scope().DroppedAttributesCount(), scope.SchemaUrl(), nil
+			if normalized {
+				id := scopeID(scope.Scope(), scope.SchemaUrl(), sattrs)
+				if err := scopes.add(ctx, id, scope.Scope().Name(), scope.Scope().Version(), sattrs, scope.Scope().DroppedAttributesCount(), scope.SchemaUrl()); err != nil {
+					return fmt.Errorf("error occured while inserting scope: %w", err)
+				}
+				snameCol, sversionCol, sattrsCol, sdroppedCol, sschemaCol, sid = nil, nil, nil, nil, nil, id
+			}
+
 			for k := 0; k < scope.Spans().Len(); k++ {
 				span := scope.Spans().At(k)
+				traceIDs[span.TraceID()] = struct{}{}
 
 				dur := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
 
-				stmt, err := tx.PrepareContext(ctx, insertSpanQ)
-				if err != nil {
-					return fmt.Errorf("failed to prepare span insert stmt: %w", err)
-				}
-
-				attrs, err := pcommonMapAsJSON(span.Attributes())
+				attrs, err := e.marshalAttributes(span.Attributes())
 				if err != nil {
 					return fmt.Errorf("failed to marshal attributes as json: %w", err)
 				}
@@ -196,7 +327,7 @@ func (e *sqliteExporter) ConsumeTraces(ctx context.Context, traces ptrace.Traces
 					parentidbs = nil
 				}
 
-				_, err = stmt.ExecContext(ctx,
+				args := []any{
 					spanidbs,
 					traceidbs,
 					parentidbs,
@@ -214,35 +345,59 @@ func (e *sqliteExporter) ConsumeTraces(ctx context.Context, traces ptrace.Traces
 					span.DroppedAttributesCount(),
 					span.DroppedEventsCount(),
 					span.DroppedLinksCount(),
-					rattrs,
-					resource.Resource().DroppedAttributesCount(),
-					scope.Scope().Name(),
-					scope.Scope().Version(),
-					sattrs,
-				)
-				if err != nil {
+					rattrsCol,
+					rdroppedCol,
+					snameCol,
+					sversionCol,
+					sattrsCol,
+					rid,
+					sid,
+					rschemaCol,
+					sschemaCol,
+					sdroppedCol,
+					e.unixNano(span.StartTimestamp()),
+					e.unixNano(span.EndTimestamp()),
+					e.durationNano(span.StartTimestamp(), span.EndTimestamp()),
+				}
+				args = append(args, hoistedValues(e.hoisted, resource.Resource().Attributes(), span.Attributes())...)
+				if e.rollups != nil {
+					args = append(args, 1)
+				}
+				if e.serviceGraph != nil {
+					// only spans with a parent are calls from another span.
+					var pending any
+					if parentidbs != nil {
+						pending = 1
+					}
+					args = append(args, pending)
+				}
+				if err := spans.add(ctx, args...); err != nil {
 					return fmt.Errorf("error occured while inserting span: %w", err)
 				}
 
+				if attrIndex != nil {
+					if err := e.attributeIndex.add(ctx, attrIndex, traceidbs, spanidbs, span.Attributes()); err != nil {
+						return fmt.Errorf("error occured while inserting span attribute: %w", err)
+					}
+				}
+
 				for l := 0; l < span.Events().Len(); l++ {
 					event := span.Events().At(l)
 
-					attrs, err := pcommonMapAsJSON(event.Attributes())
+					attrs, err := e.marshalAttributes(event.Attributes())
 					if err != nil {
 						return fmt.Errorf("failed to marshal event attributes as json: %w", err)
 					}
 
-					stmt, err := tx.PrepareContext(ctx, insertEventQ)
-					if err != nil {
-						return fmt.Errorf("failed to prepare event insert query: %w", err)
-					}
-
-					_, err = stmt.ExecContext(ctx,
+					err = events.add(ctx,
 						spanidbs,
+						traceidbs,
 						unixMicro(event.Timestamp().AsTime()),
 						event.Name(),
 						attrs,
 						event.DroppedAttributesCount(),
+						l,
+						e.unixNano(event.Timestamp()),
 					)
 					if err != nil {
 						return fmt.Errorf("error occured while inserting event: %w", err)
@@ -252,28 +407,25 @@ func (e *sqliteExporter) ConsumeTraces(ctx context.Context, traces ptrace.Traces
 				for l := 0; l < span.Links().Len(); l++ {
 					link := span.Links().At(l)
 
-					attrs, err := pcommonMapAsJSON(link.Attributes())
+					attrs, err := e.marshalAttributes(link.Attributes())
 					if err != nil {
 						return fmt.Errorf("failed to marshal link attributes as json: %w", err)
 					}
 
-					stmt, err := tx.PrepareContext(ctx, insertLinkQ)
-					if err != nil {
-						return fmt.Errorf("failed to prepare link insert query: %w", err)
-					}
-
 					linkidraw := [8]byte(link.SpanID())
 					linkidbs := linkidraw[:]
 					linktraceraw := [16]byte(link.TraceID())
 					linetracebs := linktraceraw[:]
 
-					_, err = stmt.ExecContext(ctx,
+					err = links.add(ctx,
 						spanidbs,
+						traceidbs,
 						linkidbs,
 						linetracebs,
 						link.TraceState().AsRaw(),
 						attrs,
 						link.DroppedAttributesCount(),
+						l,
 					)
 					if err != nil {
 						return fmt.Errorf("error occured while inserting link: %w", err)
@@ -283,9 +435,59 @@ func (e *sqliteExporter) ConsumeTraces(ctx context.Context, traces ptrace.Traces
 		}
 	}
 
+	// the remaining spans are flushed first, events and links would flush
+	// them anyway before writing their own rows.
+	if err := spans.flush(ctx); err != nil {
+		return fmt.Errorf("error occured while inserting span: %w", err)
+	}
+	if err := events.flush(ctx); err != nil {
+		return fmt.Errorf("error occured while inserting event: %w", err)
+	}
+	if err := links.flush(ctx); err != nil {
+		return fmt.Errorf("error occured while inserting link: %w", err)
+	}
+	if attrIndex != nil {
+		if err := attrIndex.flush(ctx); err != nil {
+			return fmt.Errorf("error occured while inserting span attribute: %w", err)
+		}
+	}
+
+	if err := updateTraces(ctx, tx, traceIDs); err != nil {
+		return err
+	}
+
+	if e.rollups != nil {
+		if err := e.rollups.update(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if e.serviceGraph != nil {
+		if err := e.serviceGraph.update(ctx, tx, traceIDs); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// serviceName returns the value of the `service.name` resource attribute, or
+// "unknown" if it isn't set.
+func serviceName(res pcommon.Resource) string {
+	svc := "unknown"
+	res.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if k == "service.name" {
+			svc = v.Str()
+			if svc == "" { // protect against service name being another type for some reason
+				svc = "unknown"
+			}
+			return false
+		}
+		return true
+	})
+	return svc
+}
+
 func pcommonMapAsJSON(m pcommon.Map) ([]byte, error) {
 	return json.Marshal(m.AsRaw())
 }
@@ -293,3 +495,21 @@ func pcommonMapAsJSON(m pcommon.Map) ([]byte, error) {
 func unixMicro(t time.Time) int64 {
 	return t.UnixNano() / 1000
 }
+
+// unixNano returns the value of a nanosecond timestamp column, NULL unless
+// the exporter writes nanosecond timestamps.
+func (e *sqliteExporter) unixNano(ts pcommon.Timestamp) any {
+	if !e.nanoseconds {
+		return nil
+	}
+	return int64(ts)
+}
+
+// durationNano returns the value of the nanosecond duration column, NULL
+// unless the exporter writes nanosecond timestamps.
+func (e *sqliteExporter) durationNano(start, end pcommon.Timestamp) any {
+	if !e.nanoseconds {
+		return nil
+	}
+	return int64(end) - int64(start)
+}
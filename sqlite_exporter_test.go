@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -493,3 +494,426 @@ func Test_ExporterExportSpanFilesystem(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 1, total)
 }
+
+func Test_ExporterMultiRowInsert(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	err = doMigrate(db)
+	require.NoError(t, err)
+
+	// 10 spans with 3 rows per statement exercises both full batches and the
+	// partial batch flushed at the end.
+	ex := sqliteExporter{db: db, rowsPerStatement: 3}
+	err = ex.ConsumeTraces(ctx, benchmarkTraces(0, 10))
+	require.NoError(t, err)
+
+	for table, expected := range map[string]int{"spans": 10, "events": 20, "links": 10} {
+		var total int
+		err = db.QueryRow(fmt.Sprintf("select count(1) from %s;", table)).Scan(&total)
+		require.NoError(t, err)
+		assert.Equal(t, expected, total, table)
+	}
+
+	var name string
+	var statusCode int
+	err = db.QueryRow("select name, status_code from spans where span_id = ?;", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09}).Scan(&name, &statusCode)
+	require.NoError(t, err)
+	assert.Equal(t, "bench-span", name)
+}
+
+func Test_ExporterOnConflict(t *testing.T) {
+	ctx := context.Background()
+
+	count := func(t *testing.T, db *sql.DB, table string) int {
+		var total int
+		err := db.QueryRow(fmt.Sprintf("select count(1) from %s;", table)).Scan(&total)
+		require.NoError(t, err)
+		return total
+	}
+
+	for _, onConflict := range []string{OnConflictIgnore, OnConflictReplace} {
+		t.Run(onConflict, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", ":memory:")
+			require.NoError(t, err)
+			require.NoError(t, doMigrate(db))
+
+			ex := sqliteExporter{db: db, onConflict: onConflict}
+			require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 5)))
+
+			// sending the same batch again, like a retry would, doesn't
+			// duplicate any row.
+			traces := benchmarkTraces(0, 5)
+			traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetName("renamed")
+			require.NoError(t, ex.ConsumeTraces(ctx, traces))
+
+			assert.Equal(t, 5, count(t, db, "spans"))
+			assert.Equal(t, 10, count(t, db, "events"))
+			assert.Equal(t, 5, count(t, db, "links"))
+
+			var renamed int
+			err = db.QueryRow("select count(1) from spans where name = 'renamed';").Scan(&renamed)
+			require.NoError(t, err)
+			if onConflict == OnConflictReplace {
+				assert.Equal(t, 1, renamed)
+			} else {
+				assert.Equal(t, 0, renamed)
+			}
+		})
+	}
+
+	t.Run(OnConflictFail, func(t *testing.T) {
+		db, err := sql.Open("sqlite3", ":memory:")
+		require.NoError(t, err)
+		require.NoError(t, doMigrate(db))
+
+		ex := sqliteExporter{db: db, onConflict: OnConflictFail}
+		require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 5)))
+
+		// a batch of new spans ending with a duplicate is rejected as a whole.
+		traces := benchmarkTraces(1, 5)
+		benchmarkTraces(0, 1).ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).
+			CopyTo(traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans().AppendEmpty())
+		require.Error(t, ex.ConsumeTraces(ctx, traces))
+
+		assert.Equal(t, 5, count(t, db, "spans"))
+		assert.Equal(t, 10, count(t, db, "events"))
+		assert.Equal(t, 5, count(t, db, "links"))
+	})
+}
+
+func Test_ExporterSharedSpanID(t *testing.T) {
+	ctx := context.Background()
+
+	for _, onConflict := range []string{OnConflictIgnore, OnConflictReplace, OnConflictFail} {
+		t.Run(onConflict, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", ":memory:")
+			require.NoError(t, err)
+			require.NoError(t, doMigrate(db))
+
+			// both batches use the same span IDs in different traces, they
+			// must not conflict with each other.
+			ex := sqliteExporter{db: db, onConflict: onConflict}
+			require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 5)))
+			require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(1, 5)))
+
+			for table, expected := range map[string]int{"spans": 10, "events": 20, "links": 10} {
+				var total int
+				err = db.QueryRow(fmt.Sprintf("select count(1) from %s;", table)).Scan(&total)
+				require.NoError(t, err)
+				assert.Equal(t, expected, total, table)
+			}
+
+			var traces int
+			err = db.QueryRow("select count(distinct trace_id) from events;").Scan(&traces)
+			require.NoError(t, err)
+			assert.Equal(t, 10, traces)
+		})
+	}
+}
+
+func Test_ExporterForeignKeys(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = filepath.Join(t.TempDir(), "fk.db")
+	cfg.Pragmas.ForeignKeys = true
+	cfg.RowsPerStatement = 3
+
+	ex, err := newSqliteExporter(cfg)
+	require.NoError(t, err)
+	defer ex.Shutdown(ctx)
+
+	require.NoError(t, ex.ConsumeTraces(ctx, benchmarkTraces(0, 10)))
+
+	var violations int
+	require.NoError(t, ex.db.QueryRow("select count(1) from pragma_foreign_key_check;").Scan(&violations))
+	assert.Equal(t, 0, violations)
+
+	// events must reference an existing span
+	_, err = ex.db.Exec("insert into events (span_id, trace_id, event_index) values (x'01', x'02', 0);")
+	assert.Error(t, err)
+}
+
+func Test_ExporterTimestampPrecision(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 123456789)
+
+	traces := ptrace.NewTraces()
+	span := traces.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{0x01})
+	span.SetSpanID(pcommon.SpanID{0x01})
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(500 * time.Nanosecond)))
+	for _, offset := range []time.Duration{200, 100} {
+		span.Events().AppendEmpty().SetTimestamp(pcommon.NewTimestampFromTime(start.Add(offset)))
+	}
+
+	for _, tt := range []struct {
+		precision string
+		expected  []int64
+	}{
+		// the view falls back to the microsecond timestamps.
+		{TimestampPrecisionMicro, []int64{1700000000123456000, 1700000000123457000, 0}},
+		{TimestampPrecisionNano, []int64{1700000000123456789, 1700000000123457289, 500}},
+	} {
+		t.Run(tt.precision, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Path = filepath.Join(t.TempDir(), "precision.db")
+			cfg.TimestampPrecision = tt.precision
+
+			ex, err := newSqliteExporter(cfg)
+			require.NoError(t, err)
+			defer ex.Shutdown(ctx)
+			require.NoError(t, ex.ConsumeTraces(ctx, traces))
+
+			// the microsecond columns are written with either precision.
+			var startMicro, endMicro, durationMicro int64
+			require.NoError(t, ex.db.QueryRow("select start_time, end_time, __duration from spans;").Scan(&startMicro, &endMicro, &durationMicro))
+			assert.Equal(t, []int64{1700000000123456, 1700000000123457, 0}, []int64{startMicro, endMicro, durationMicro})
+
+			var startNano, endNano, durationNano int64
+			require.NoError(t, ex.db.QueryRow("select start_time_ns, end_time_ns, __duration_ns from spans_denormalized;").Scan(&startNano, &endNano, &durationNano))
+			assert.Equal(t, tt.expected, []int64{startNano, endNano, durationNano})
+
+			rows, err := ex.db.Query("select event_index from events_ns order by timestamp_ns, event_index;")
+			require.NoError(t, err)
+			defer rows.Close()
+			var order []int
+			for rows.Next() {
+				var i int
+				require.NoError(t, rows.Scan(&i))
+				order = append(order, i)
+			}
+			require.NoError(t, rows.Err())
+			if tt.precision == TimestampPrecisionNano {
+				assert.Equal(t, []int{1, 0}, order)
+			} else {
+				// both events are in the same microsecond.
+				assert.Equal(t, []int{0, 1}, order)
+			}
+		})
+	}
+}
+
+func Test_insertSpecQuery(t *testing.T) {
+	spec := insertSpec{
+		table:   "t",
+		columns: []string{"a", "b"},
+		values:  []string{"?", "json(?)"},
+	}
+	assert.Equal(t, "INSERT INTO t (a, b) VALUES (?, json(?));", spec.query(1))
+	assert.Equal(t, "INSERT INTO t (a, b) VALUES (?, json(?)), (?, json(?));", spec.query(2))
+}
+
+func Test_batchInserterFlushesParent(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE parent(id INTEGER PRIMARY KEY);
+		CREATE TABLE child(parent_id INTEGER REFERENCES parent(id));`)
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	parent := newBatchInserter(tx, insertSpec{table: "parent", columns: []string{"id"}, values: []string{"?"}}, 3)
+	defer parent.close()
+	child := newBatchInserter(tx, insertSpec{table: "child", columns: []string{"parent_id"}, values: []string{"?"}}, 2).withParent(parent)
+	defer child.close()
+
+	// the child batch is full before the parent one, the buffered parent row
+	// must be written first.
+	require.NoError(t, parent.add(ctx, 1))
+	require.NoError(t, child.add(ctx, 1))
+	require.NoError(t, child.add(ctx, 1))
+	require.NoError(t, child.flush(ctx))
+	require.NoError(t, parent.flush(ctx))
+	require.NoError(t, tx.Commit())
+
+	var total int
+	require.NoError(t, db.QueryRow("select count(1) from child;").Scan(&total))
+	assert.Equal(t, 2, total)
+}
+
+func BenchmarkConsumeTraces(b *testing.B) {
+	const spansPerBatch = 1000
+
+	// the insert path from before statements were reused, as a reference
+	// point for the cases below.
+	b.Run("prepare_per_row", func(b *testing.B) {
+		ctx := context.Background()
+		cfg := createDefaultConfig().(*Config)
+		cfg.Path = filepath.Join(b.TempDir(), "bench.db")
+
+		ex, err := newSqliteExporter(cfg)
+		require.NoError(b, err)
+		defer ex.Shutdown(ctx)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			traces := benchmarkTraces(i, spansPerBatch)
+			b.StartTimer()
+
+			require.NoError(b, consumeTracesPreparePerRow(ctx, ex.db, traces))
+		}
+		b.ReportMetric(float64(b.N*spansPerBatch)/b.Elapsed().Seconds(), "spans/s")
+	})
+
+	for _, rows := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("rows_per_statement=%d", rows), func(b *testing.B) {
+			ctx := context.Background()
+			cfg := createDefaultConfig().(*Config)
+			cfg.Path = filepath.Join(b.TempDir(), "bench.db")
+			cfg.RowsPerStatement = rows
+
+			ex, err := newSqliteExporter(cfg)
+			require.NoError(b, err)
+			defer ex.Shutdown(ctx)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				traces := benchmarkTraces(i, spansPerBatch)
+				b.StartTimer()
+
+				require.NoError(b, ex.ConsumeTraces(ctx, traces))
+			}
+			b.ReportMetric(float64(b.N*spansPerBatch)/b.Elapsed().Seconds(), "spans/s")
+		})
+	}
+}
+
+// consumeTracesPreparePerRow writes spans, events and links the way
+// ConsumeTraces did before statements were reused: every row prepares its own
+// single-row INSERT statement.
+func consumeTracesPreparePerRow(ctx context.Context, db *sql.DB, traces ptrace.Traces) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exec := func(spec insertSpec, args ...any) error {
+		stmt, err := tx.PrepareContext(ctx, spec.withConflict(OnConflictIgnore).query(1))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		_, err = stmt.ExecContext(ctx, args...)
+		return err
+	}
+
+	for i := 0; i < traces.ResourceSpans().Len(); i++ {
+		resource := traces.ResourceSpans().At(i)
+		svc := serviceName(resource.Resource())
+		rattrs, err := pcommonMapAsJSON(resource.Resource().Attributes())
+		if err != nil {
+			return err
+		}
+
+		for j := 0; j < resource.ScopeSpans().Len(); j++ {
+			scope := resource.ScopeSpans().At(j)
+			sattrs, err := pcommonMapAsJSON(scope.Scope().Attributes())
+			if err != nil {
+				return err
+			}
+
+			for k := 0; k < scope.Spans().Len(); k++ {
+				span := scope.Spans().At(k)
+				attrs, err := pcommonMapAsJSON(span.Attributes())
+				if err != nil {
+					return err
+				}
+
+				spanID, traceID := span.SpanID(), span.TraceID()
+				err = exec(spansSpec,
+					spanID[:], traceID[:], nil, span.TraceState().AsRaw(), svc,
+					span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime()).Microseconds(),
+					span.Name(), span.Kind().String(),
+					unixMicro(span.StartTimestamp().AsTime()), unixMicro(span.EndTimestamp().AsTime()),
+					span.Status().Code(), span.Status().Message(), attrs,
+					span.DroppedAttributesCount(), span.DroppedEventsCount(), span.DroppedLinksCount(),
+					rattrs, resource.Resource().DroppedAttributesCount(),
+					scope.Scope().Name(), scope.Scope().Version(), sattrs, nil, nil,
+					resource.SchemaUrl(), scope.SchemaUrl(), scope.Scope().DroppedAttributesCount(),
+					nil, nil, nil,
+				)
+				if err != nil {
+					return err
+				}
+
+				for l := 0; l < span.Events().Len(); l++ {
+					event := span.Events().At(l)
+					attrs, err := pcommonMapAsJSON(event.Attributes())
+					if err != nil {
+						return err
+					}
+					err = exec(eventsSpec, spanID[:], traceID[:], unixMicro(event.Timestamp().AsTime()),
+						event.Name(), attrs, event.DroppedAttributesCount(), l, nil)
+					if err != nil {
+						return err
+					}
+				}
+
+				for l := 0; l < span.Links().Len(); l++ {
+					link := span.Links().At(l)
+					attrs, err := pcommonMapAsJSON(link.Attributes())
+					if err != nil {
+						return err
+					}
+					linkSpanID, linkTraceID := link.SpanID(), link.TraceID()
+					err = exec(linksSpec, spanID[:], traceID[:], linkSpanID[:], linkTraceID[:],
+						link.TraceState().AsRaw(), attrs, link.DroppedAttributesCount(), l)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// benchmarkTraces returns a batch of n spans, each with two events and a link.
+// The seed is used to generate unique trace IDs across batches.
+func benchmarkTraces(seed, n int) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "bench-service")
+	rs.Resource().Attributes().PutStr("host.name", "localhost")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("bench-scope")
+
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		span := ss.Spans().AppendEmpty()
+		span.SetTraceID(pcommon.TraceID{byte(seed >> 24), byte(seed >> 16), byte(seed >> 8), byte(seed), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(i >> 8), byte(i)})
+		span.SetSpanID(pcommon.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(i >> 8), byte(i)})
+		span.SetName("bench-span")
+		span.SetKind(ptrace.SpanKindServer)
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-5 * time.Millisecond)))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(now))
+		span.Attributes().PutStr("http.method", "GET")
+		span.Attributes().PutInt("http.status_code", 200)
+
+		for j := 0; j < 2; j++ {
+			ev := span.Events().AppendEmpty()
+			ev.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Duration(j) * time.Millisecond)))
+			ev.SetName(fmt.Sprintf("event-%d", j))
+			ev.Attributes().PutStr("value", "example")
+		}
+
+		link := span.Links().AppendEmpty()
+		link.SetTraceID(span.TraceID())
+		link.SetSpanID(pcommon.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff})
+	}
+	return traces
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+)
+
+const (
+	getQuery    = "SELECT value FROM storage WHERE client = ? AND key = ?;"
+	setQuery    = "INSERT INTO storage (client, key, value) VALUES (?, ?, ?) ON CONFLICT (client, key) DO UPDATE SET value = excluded.value;"
+	deleteQuery = "DELETE FROM storage WHERE client = ? AND key = ?;"
+)
+
+var _ storage.Client = (*client)(nil)
+
+// client reads and writes the keys of a single component. The database is
+// owned by the extension, closing the client doesn't close it.
+type client struct {
+	db   *sql.DB
+	name string
+}
+
+// dbtx is implemented by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Get returns the value stored for key, or nil if there is none.
+func (c *client) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.get(ctx, c.db, key)
+}
+
+func (c *client) Set(ctx context.Context, key string, value []byte) error {
+	return c.set(ctx, c.db, key, value)
+}
+
+func (c *client) Delete(ctx context.Context, key string) error {
+	return c.delete(ctx, c.db, key)
+}
+
+// Batch runs the operations in order in a single transaction. The value of
+// each get operation is set to the value read.
+func (c *client) Batch(ctx context.Context, ops ...storage.Operation) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, op := range ops {
+		switch op.Type {
+		case storage.Get:
+			op.Value, err = c.get(ctx, tx, op.Key)
+		case storage.Set:
+			err = c.set(ctx, tx, op.Key, op.Value)
+		case storage.Delete:
+			err = c.delete(ctx, tx, op.Key)
+		default:
+			err = fmt.Errorf("unsupported operation type %d", op.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (c *client) Close(context.Context) error {
+	return nil
+}
+
+func (c *client) get(ctx context.Context, e dbtx, key string) ([]byte, error) {
+	var value []byte
+	err := e.QueryRowContext(ctx, getQuery, c.name, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %q: %w", key, err)
+	}
+	return value, nil
+}
+
+func (c *client) set(ctx context.Context, e dbtx, key string, value []byte) error {
+	if _, err := e.ExecContext(ctx, setQuery, c.name, key, value); err != nil {
+		return fmt.Errorf("failed to set %q: %w", key, err)
+	}
+	return nil
+}
+
+func (c *client) delete(ctx context.Context, e dbtx, key string) error {
+	if _, err := e.ExecContext(ctx, deleteQuery, c.name, key); err != nil {
+		return fmt.Errorf("failed to delete %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqlitestorage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+var _ component.Config = (*Config)(nil)
+
+type Config struct {
+	// Path of the sqlite3 database file. Path is relative to current directory.
+	// If file does not exist, it will be created by the extension.
+	Path string `mapstructure:"path"`
+
+	// Synchronous is one of off, normal, full or extra. Defaults to normal.
+	Synchronous string `mapstructure:"synchronous"`
+
+	// BusyTimeout is how long a connection waits on a locked database before
+	// returning SQLITE_BUSY. Defaults to 5s.
+	BusyTimeout time.Duration `mapstructure:"busy_timeout"`
+}
+
+var synchronous = []string{"off", "normal", "full", "extra"}
+
+func (cfg *Config) Validate() error {
+	if cfg.Path == "" {
+		return errors.New("path must be non-empty")
+	}
+
+	valid := false
+	for _, s := range synchronous {
+		valid = valid || strings.EqualFold(cfg.Synchronous, s)
+	}
+	if !valid {
+		return fmt.Errorf("synchronous must be one of %s, got %q", strings.Join(synchronous, ", "), cfg.Synchronous)
+	}
+
+	if cfg.BusyTimeout < 0 {
+		return errors.New("busy_timeout must be non-negative")
+	}
+
+	return nil
+}
+
+// pragmas returns the PRAGMA statements to run on each new connection. The
+// database is always in WAL mode, so that the collector can read the queue
+// while it's being written to.
+func (cfg *Config) pragmas() []string {
+	return []string{
+		"PRAGMA journal_mode = wal;",
+		fmt.Sprintf("PRAGMA synchronous = %s;", strings.ToLower(cfg.Synchronous)),
+		fmt.Sprintf("PRAGMA busy_timeout = %d;", cfg.BusyTimeout.Milliseconds()),
+	}
+}
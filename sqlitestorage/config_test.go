@@ -0,0 +1,72 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqlitestorage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap/confmaptest"
+
+	"go.wperron.io/sqliteexporter/sqlitestorage/internal/metadata"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		id           component.ID
+		expected     component.Config
+		errorMessage string
+	}{
+		{
+			id: component.NewIDWithName(metadata.Type, "1"),
+			expected: &Config{
+				Path:        "./queue.db",
+				Synchronous: "normal",
+				BusyTimeout: 5 * time.Second,
+			},
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "2"),
+			errorMessage: "path must be non-empty",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "3"),
+			errorMessage: "synchronous must be one of off, normal, full, extra, got \"yolo\"",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "4"),
+			expected: &Config{
+				Path:        "./queue.db",
+				Synchronous: "full",
+				BusyTimeout: 10 * time.Second,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id.String(), func(t *testing.T) {
+			factory := NewFactory()
+			cfg := factory.CreateDefaultConfig()
+
+			sub, err := cm.Sub(tt.id.String())
+			require.NoError(t, err)
+			require.NoError(t, component.UnmarshalConfig(sub, cfg))
+
+			if tt.expected == nil {
+				assert.EqualError(t, component.ValidateConfig(cfg), tt.errorMessage)
+				return
+			}
+
+			assert.NoError(t, component.ValidateConfig(cfg))
+			assert.Equal(t, tt.expected, cfg)
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+
+// Package sqlitestorage implements the collector's storage extension on top of
+// a sqlite database file.
+package sqlitestorage
@@ -0,0 +1,93 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqlitestorage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.uber.org/zap"
+
+	"go.wperron.io/sqliteexporter/internal/sqlitedb"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var errNotStarted = errors.New("sqlite storage extension is not started")
+
+var _ storage.Extension = (*sqliteStorage)(nil)
+
+// sqliteStorage stores the data of every client in a single table of the
+// database, keyed by the client name.
+type sqliteStorage struct {
+	cfg    *Config
+	logger *zap.Logger
+	db     *sql.DB
+}
+
+func newSqliteStorage(cfg *Config, logger *zap.Logger) *sqliteStorage {
+	return &sqliteStorage{
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+func (s *sqliteStorage) Start(context.Context, component.Host) error {
+	db := sqlitedb.Open(s.cfg.Path, s.cfg.pragmas())
+
+	// IMPORTANT: database/sql opens a connection pool by default, but sqlite
+	// only allows a single connection to be open at the same time.
+	db.SetMaxOpenConns(1)
+
+	if err := sqlitedb.Migrate(db, migrations, "migrations", "schema_migrations_sqlitestorage"); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	return nil
+}
+
+func (s *sqliteStorage) Shutdown(context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// GetClient returns a client storing its data under a name unique to the
+// component, following the naming of the file storage extension.
+func (s *sqliteStorage) GetClient(_ context.Context, kind component.Kind, id component.ID, name string) (storage.Client, error) {
+	if s.db == nil {
+		return nil, errNotStarted
+	}
+
+	clientName := fmt.Sprintf("%s_%s_%s", kindString(kind), id.Type(), id.Name())
+	if name != "" {
+		clientName = fmt.Sprintf("%s_%s", clientName, name)
+	}
+
+	return &client{db: s.db, name: clientName}, nil
+}
+
+func kindString(k component.Kind) string {
+	switch k {
+	case component.KindReceiver:
+		return "receiver"
+	case component.KindProcessor:
+		return "processor"
+	case component.KindExporter:
+		return "exporter"
+	case component.KindExtension:
+		return "extension"
+	case component.KindConnector:
+		return "connector"
+	default:
+		return "other"
+	}
+}
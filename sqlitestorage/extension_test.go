@@ -0,0 +1,121 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqlitestorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+
+	"go.wperron.io/sqliteexporter/sqlitestorage/internal/metadata"
+)
+
+func newTestStorage(t *testing.T, path string) storage.Extension {
+	ctx := context.Background()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = path
+
+	set := extension.CreateSettings{
+		ID:                component.NewID(metadata.Type),
+		TelemetrySettings: componenttest.NewNopTelemetrySettings(),
+	}
+	ext, err := NewFactory().CreateExtension(ctx, set, cfg)
+	require.NoError(t, err)
+	require.NoError(t, ext.Start(ctx, componenttest.NewNopHost()))
+
+	return ext.(storage.Extension)
+}
+
+func newTestClient(t *testing.T, ext storage.Extension, name string) storage.Client {
+	c, err := ext.GetClient(context.Background(), component.KindExporter, component.NewID("otlp"), name)
+	require.NoError(t, err)
+	return c
+}
+
+func TestClientGetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	ext := newTestStorage(t, filepath.Join(t.TempDir(), "queue.db"))
+	defer ext.Shutdown(ctx)
+	c := newTestClient(t, ext, "")
+
+	value, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	require.NoError(t, c.Set(ctx, "key", []byte("first")))
+	require.NoError(t, c.Set(ctx, "key", []byte("second")))
+	value, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), value)
+
+	require.NoError(t, c.Delete(ctx, "key"))
+	value, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// deleting a missing key isn't an error.
+	require.NoError(t, c.Delete(ctx, "key"))
+	require.NoError(t, c.Close(ctx))
+}
+
+func TestClientBatch(t *testing.T) {
+	ctx := context.Background()
+	ext := newTestStorage(t, filepath.Join(t.TempDir(), "queue.db"))
+	defer ext.Shutdown(ctx)
+	c := newTestClient(t, ext, "")
+
+	require.NoError(t, c.Set(ctx, "deleted", []byte("value")))
+
+	get := storage.GetOperation("set")
+	missing := storage.GetOperation("deleted")
+	require.NoError(t, c.Batch(ctx,
+		storage.SetOperation("set", []byte("value")),
+		storage.DeleteOperation("deleted"),
+		get,
+		missing,
+	))
+	assert.Equal(t, []byte("value"), get.Value)
+	assert.Nil(t, missing.Value)
+}
+
+func TestClientsAreIsolated(t *testing.T) {
+	ctx := context.Background()
+	ext := newTestStorage(t, filepath.Join(t.TempDir(), "queue.db"))
+	defer ext.Shutdown(ctx)
+
+	queue := newTestClient(t, ext, "queue")
+	other := newTestClient(t, ext, "")
+	require.NoError(t, queue.Set(ctx, "key", []byte("queue")))
+
+	value, err := other.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestStoragePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queue.db")
+
+	ext := newTestStorage(t, path)
+	require.NoError(t, newTestClient(t, ext, "queue").Set(ctx, "key", []byte("value")))
+	require.NoError(t, ext.Shutdown(ctx))
+
+	ext = newTestStorage(t, path)
+	defer ext.Shutdown(ctx)
+	value, err := newTestClient(t, ext, "queue").Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestGetClientNotStarted(t *testing.T) {
+	ext := newSqliteStorage(createDefaultConfig().(*Config), nil)
+	_, err := ext.GetClient(context.Background(), component.KindExporter, component.NewID("otlp"), "")
+	assert.ErrorIs(t, err, errNotStarted)
+	assert.NoError(t, ext.Shutdown(context.Background()))
+}
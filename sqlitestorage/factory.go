@@ -0,0 +1,33 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqlitestorage
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+
+	"go.wperron.io/sqliteexporter/sqlitestorage/internal/metadata"
+)
+
+// NewFactory creates a factory for the sqlite storage extension.
+func NewFactory() extension.Factory {
+	return extension.NewFactory(
+		metadata.Type,
+		createDefaultConfig,
+		createExtension,
+		metadata.ExtensionStability,
+	)
+}
+
+func createDefaultConfig() component.Config {
+	return &Config{
+		Synchronous: "normal",
+		BusyTimeout: 5 * time.Second,
+	}
+}
+
+func createExtension(_ context.Context, set extension.CreateSettings, cfg component.Config) (extension.Extension, error) {
+	return newSqliteStorage(cfg.(*Config), set.Logger), nil
+}
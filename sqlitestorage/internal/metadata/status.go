@@ -0,0 +1,12 @@
+// Copyright 2024 William Perron. All rights reserved. MIT license
+// TODO(wperron) this file is normally auto-generated, set up that process.
+package metadata
+
+import (
+	"go.opentelemetry.io/collector/component"
+)
+
+const (
+	Type               = "sqlite_storage"
+	ExtensionStability = component.StabilityLevelAlpha
+)
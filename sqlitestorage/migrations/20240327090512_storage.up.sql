@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS storage (
+    client TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value BLOB,
+    PRIMARY KEY (client, key)
+) WITHOUT ROWID;
@@ -0,0 +1,112 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const deleteTraceSummaryQ = `DELETE FROM traces WHERE trace_id = ?;`
+
+// insertTraceSummaryQ computes the summary of a trace from all of its stored
+// spans, it doesn't insert anything when the trace has no spans left. It is
+// the same statement as the one backfilling the traces table in the
+// migrations, for a single trace.
+const insertTraceSummaryQ = `INSERT OR REPLACE INTO traces (trace_id, root_service_name, root_span_name, start_time, end_time, start_time_ns, end_time_ns, span_count, error_count, services)
+SELECT
+	spans.trace_id,
+	(SELECT root.__service_name FROM spans AS root WHERE root.trace_id = spans.trace_id AND root.parent_span_id IS NULL ORDER BY root.start_time, root.span_id LIMIT 1),
+	(SELECT root.name FROM spans AS root WHERE root.trace_id = spans.trace_id AND root.parent_span_id IS NULL ORDER BY root.start_time, root.span_id LIMIT 1),
+	min(spans.start_time),
+	max(spans.end_time),
+	min(coalesce(spans.start_time_ns, spans.start_time * 1000)),
+	max(coalesce(spans.end_time_ns, spans.end_time * 1000)),
+	count(*),
+	count(*) FILTER (WHERE spans.status_code = 2),
+	(SELECT json_group_array(name) FROM (SELECT DISTINCT s.__service_name AS name FROM spans AS s WHERE s.trace_id = spans.trace_id ORDER BY name))
+FROM spans
+WHERE spans.trace_id = ?
+GROUP BY spans.trace_id;`
+
+// traceStatements are run, in order, for every trace with spans written or
+// deleted, to update the data derived from all the stored spans of the
+// trace. Recomputing it from every span, rather than adding the spans of a
+// batch to it, keeps it correct whatever the order the spans of a trace
+// arrive in, and when spans are ignored or replaced on conflict.
+var traceStatements = []struct {
+	name  string
+	query string
+}{
+	{"span ancestry", insertSpanAncestryQ},
+	{"span trees", updateSpanTreesQ},
+	{"self times", updateSelfTimesQ},
+	// the summary of a trace without any span left is deleted.
+	{"trace summary", deleteTraceSummaryQ},
+	{"trace summary", insertTraceSummaryQ},
+}
+
+// updateTraces updates the span trees, self times, critical paths and
+// summaries of the traces.
+func updateTraces(ctx context.Context, tx *sql.Tx, traceIDs map[pcommon.TraceID]struct{}) error {
+	if len(traceIDs) == 0 {
+		return nil
+	}
+
+	stmts := make([]*sql.Stmt, 0, len(traceStatements))
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	for _, ts := range traceStatements {
+		stmt, err := tx.PrepareContext(ctx, ts.query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare %s stmt: %w", ts.name, err)
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	cp, err := newCriticalPathWriter(ctx, tx)
+	if err != nil {
+		return err
+	}
+	defer cp.close()
+
+	for id := range traceIDs {
+		traceidraw := [16]byte(id)
+		for i, stmt := range stmts {
+			if _, err := stmt.ExecContext(ctx, traceidraw[:]); err != nil {
+				return fmt.Errorf("failed to update %s of trace %s: %w", traceStatements[i].name, id, err)
+			}
+		}
+		if err := cp.update(ctx, traceidraw[:]); err != nil {
+			return fmt.Errorf("failed to update critical path of trace %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// matchingTraceIDs returns the IDs of the traces with a span matching the
+// condition.
+func matchingTraceIDs(ctx context.Context, tx *sql.Tx, cond string, args ...any) (map[pcommon.TraceID]struct{}, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT trace_id FROM spans WHERE "+cond+";", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[pcommon.TraceID]struct{})
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		var id pcommon.TraceID
+		copy(id[:], b)
+		ids[id] = struct{}{}
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type traceSummary struct {
+	RootService, RootName sql.NullString
+	Start, End            int64
+	SpanCount, ErrorCount int
+	Services              string
+}
+
+func readTraceSummary(t *testing.T, db *sql.DB, traceID pcommon.TraceID) (traceSummary, bool) {
+	var s traceSummary
+	err := db.QueryRow(
+		"select root_service_name, root_span_name, start_time, end_time, span_count, error_count, services from traces where trace_id = ?;",
+		traceID[:],
+	).Scan(&s.RootService, &s.RootName, &s.Start, &s.End, &s.SpanCount, &s.ErrorCount, &s.Services)
+	if err == sql.ErrNoRows {
+		return s, false
+	}
+	require.NoError(t, err)
+	return s, true
+}
+
+// summarySpan returns a batch with a single span of the trace.
+func summarySpan(service string, spanID, parentID byte, start time.Time, d time.Duration, code ptrace.StatusCode) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	rs := traces.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", service)
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{0x01})
+	span.SetSpanID(pcommon.SpanID{spanID})
+	if parentID != 0 {
+		span.SetParentSpanID(pcommon.SpanID{parentID})
+	}
+	span.SetName(fmt.Sprintf("%s-%d", service, spanID))
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(d)))
+	span.Status().SetCode(code)
+	return traces
+}
+
+func Test_ExporterTraceSummaryOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{})
+
+	// the children arrive first, in two batches.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 3, 2, start.Add(20*time.Millisecond), 50*time.Millisecond, ptrace.StatusCodeError)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("checkout", 2, 1, start.Add(10*time.Millisecond), 100*time.Millisecond, ptrace.StatusCodeOk)))
+
+	s, ok := readTraceSummary(t, db, pcommon.TraceID{0x01})
+	require.True(t, ok)
+	assert.Equal(t, traceSummary{
+		Start:      unixMicro(start.Add(10 * time.Millisecond)),
+		End:        unixMicro(start.Add(110 * time.Millisecond)),
+		SpanCount:  2,
+		ErrorCount: 1,
+		Services:   `["checkout","payments"]`,
+	}, s)
+
+	// the root arrives last, and twice.
+	root := summarySpan("frontend", 1, 0, start, time.Second, ptrace.StatusCodeUnset)
+	require.NoError(t, ex.ConsumeTraces(ctx, root))
+	require.NoError(t, ex.ConsumeTraces(ctx, root))
+
+	s, ok = readTraceSummary(t, db, pcommon.TraceID{0x01})
+	require.True(t, ok)
+	assert.Equal(t, traceSummary{
+		RootService: sql.NullString{String: "frontend", Valid: true},
+		RootName:    sql.NullString{String: "frontend-1", Valid: true},
+		Start:       unixMicro(start),
+		End:         unixMicro(start.Add(time.Second)),
+		SpanCount:   3,
+		ErrorCount:  1,
+		Services:    `["checkout","frontend","payments"]`,
+	}, s)
+}
+
+func Test_ExporterPruneTraceSummary(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	ex, db := newRetentionTestExporter(t, RetentionConfig{MaxAge: time.Hour, CheckInterval: time.Minute})
+
+	// the root of the trace is old enough to be pruned, its child isn't.
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("frontend", 1, 0, now.Add(-2*time.Hour), 2*time.Hour, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.ConsumeTraces(ctx, summarySpan("payments", 2, 1, now.Add(-time.Minute), time.Second, ptrace.StatusCodeUnset)))
+	require.NoError(t, ex.prune(ctx, now))
+
+	s, ok := readTraceSummary(t, db, pcommon.TraceID{0x01})
+	require.True(t, ok)
+	assert.False(t, s.RootService.Valid)
+	assert.Equal(t, 1, s.SpanCount)
+	assert.Equal(t, `["payments"]`, s.Services)
+
+	require.NoError(t, ex.prune(ctx, now.Add(2*time.Hour)))
+	_, ok = readTraceSummary(t, db, pcommon.TraceID{0x01})
+	assert.False(t, ok)
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errWriterClosed = errors.New("sqlite exporter is shut down")
+
+// writeFunc writes a batch of telemetry within a transaction.
+type writeFunc func(ctx context.Context, tx *sql.Tx) error
+
+type writeRequest struct {
+	ctx   context.Context
+	rows  int
+	write writeFunc
+	done  chan error
+}
+
+// groupWriter is the single goroutine writing to the database. Batches that
+// arrive while it is busy, or within maxDelay of each other, are written in
+// the same transaction so they share the cost of a single commit. Each batch
+// is written within its own savepoint, a batch that fails to be written is
+// rolled back without affecting the others.
+type groupWriter struct {
+	db       *sql.DB
+	maxDelay time.Duration
+	maxRows  int
+
+	requests  chan *writeRequest
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newGroupWriter(db *sql.DB, maxDelay time.Duration, maxRows int) *groupWriter {
+	w := &groupWriter{
+		db:       db,
+		maxDelay: maxDelay,
+		maxRows:  maxRows,
+		requests: make(chan *writeRequest),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// write queues a batch and waits until it is committed or failed.
+func (w *groupWriter) write(ctx context.Context, rows int, fn writeFunc) error {
+	r := &writeRequest{ctx: ctx, rows: rows, write: fn, done: make(chan error, 1)}
+
+	select {
+	case w.requests <- r:
+	case <-w.stop:
+		return errWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// the request is always answered once accepted, even if the context is
+	// cancelled in the meantime, so that it is never left half-written.
+	return <-r.done
+}
+
+// close stops accepting new batches and waits for the pending ones to be
+// written.
+func (w *groupWriter) close() {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+}
+
+func (w *groupWriter) run() {
+	defer close(w.done)
+
+	for {
+		var first *writeRequest
+		select {
+		case <-w.stop:
+			return
+		case first = <-w.requests:
+		}
+
+		w.commit(w.collect(first))
+	}
+}
+
+// collect gathers the batches arriving after first until the maximum delay
+// or number of rows is reached. Without a delay, it only takes the batches
+// that are already waiting. A maximum of 0 rows doesn't limit the batches.
+func (w *groupWriter) collect(first *writeRequest) []*writeRequest {
+	batch := []*writeRequest{first}
+	rows := first.rows
+
+	var timeout <-chan time.Time
+	if w.maxDelay > 0 {
+		timer := time.NewTimer(w.maxDelay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for w.maxRows == 0 || rows < w.maxRows {
+		if timeout == nil {
+			select {
+			case r := <-w.requests:
+				batch = append(batch, r)
+				rows += r.rows
+				continue
+			default:
+				return batch
+			}
+		}
+
+		select {
+		case r := <-w.requests:
+			batch = append(batch, r)
+			rows += r.rows
+		case <-timeout:
+			return batch
+		case <-w.stop:
+			return batch
+		}
+	}
+	return batch
+}
+
+// commit writes the batches in a single transaction and answers each one of
+// them with its own result.
+func (w *groupWriter) commit(batch []*writeRequest) {
+	tx, err := w.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		err = fmt.Errorf("failed to start transaction: %w", err)
+		for _, r := range batch {
+			r.done <- err
+		}
+		return
+	}
+
+	var written []*writeRequest
+	for _, r := range batch {
+		if err := writeSavepoint(r.ctx, tx, r.write); err != nil {
+			r.done <- err
+			continue
+		}
+		written = append(written, r)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, r := range written {
+		r.done <- err
+	}
+}
+
+// writeSavepoint runs fn within a savepoint, rolling back only its own changes
+// if it fails.
+func writeSavepoint(ctx context.Context, tx *sql.Tx, fn writeFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// the transaction is shared with the other batches of the group, so the
+	// statements must not be interrupted when this batch's context is
+	// cancelled: the driver would interrupt the connection and roll back the
+	// whole transaction.
+	ctx = context.WithoutCancel(ctx)
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT batch;"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		_, _ = tx.ExecContext(ctx, "ROLLBACK TO batch;")
+		_, _ = tx.ExecContext(ctx, "RELEASE batch;")
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "RELEASE batch;"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// startWriter starts the goroutine writing the batches of every signal.
+func (e *sqliteExporter) startWriter() {
+	if e.writer != nil {
+		return
+	}
+	e.writer = newGroupWriter(e.db, e.maxBatchDelay, e.maxBatchRows)
+}
+
+// shutdownWriter writes the pending batches, if any, and stops the writer.
+func (e *sqliteExporter) shutdownWriter() {
+	if e.writer == nil {
+		return
+	}
+	e.writer.close()
+	e.writer = nil
+}
+
+// write writes a batch of rows, through the group writer when it is started
+// or in its own transaction otherwise.
+func (e *sqliteExporter) write(ctx context.Context, rows int, fn writeFunc) error {
+	if e.writer != nil {
+		return e.writer.write(ctx, rows, fn)
+	}
+
+	return e.withTx(ctx, func(tx *sql.Tx) error {
+		return fn(ctx, tx)
+	})
+}
+
+// withTx runs fn in a transaction, committing it if fn succeeds and rolling it
+// back otherwise.
+func (e *sqliteExporter) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
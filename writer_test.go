@@ -0,0 +1,204 @@
+// Copyright 2024 William Perron. All rights reserved. MIT License.
+package sqliteexporter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func newWriterTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE t(v INTEGER);")
+	require.NoError(t, err)
+	return db
+}
+
+// insertValue returns a write function inserting v, recording the
+// transaction it was written in.
+func insertValue(v int, txs *sync.Map) writeFunc {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		txs.Store(v, tx)
+		_, err := tx.ExecContext(ctx, "INSERT INTO t (v) VALUES (?);", v)
+		return err
+	}
+}
+
+func Test_groupWriterGroupsBatches(t *testing.T) {
+	ctx := context.Background()
+	db := newWriterTestDB(t)
+
+	w := newGroupWriter(db, time.Second, 3)
+	defer w.close()
+
+	var txs sync.Map
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, w.write(ctx, 1, insertValue(i, &txs)))
+		}(i)
+	}
+	wg.Wait()
+
+	// the third batch reaches max_batch_rows, all three are committed
+	// together without waiting for the delay to expire.
+	first, _ := txs.Load(0)
+	txs.Range(func(_, tx any) bool {
+		assert.Same(t, first, tx)
+		return true
+	})
+	assert.Equal(t, 3, countRows(t, db, "t"))
+}
+
+func Test_groupWriterIsolatesFailedBatch(t *testing.T) {
+	ctx := context.Background()
+	db := newWriterTestDB(t)
+
+	w := newGroupWriter(db, 100*time.Millisecond, 0)
+	defer w.close()
+
+	errFailed := errors.New("failed")
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	var txs sync.Map
+	var wg sync.WaitGroup
+	results := make([]error, 4)
+	writes := []struct {
+		ctx context.Context
+		fn  writeFunc
+	}{
+		{ctx, insertValue(0, &txs)},
+		{ctx, func(ctx context.Context, tx *sql.Tx) error {
+			// the row is written before failing, it must be rolled back.
+			if err := insertValue(1, &txs)(ctx, tx); err != nil {
+				return err
+			}
+			return errFailed
+		}},
+		{cancelled, insertValue(2, &txs)},
+		{ctx, insertValue(3, &txs)},
+	}
+	for i, wr := range writes {
+		wg.Add(1)
+		go func(i int, ctx context.Context, fn writeFunc) {
+			defer wg.Done()
+			results[i] = w.write(ctx, 1, fn)
+		}(i, wr.ctx, wr.fn)
+	}
+	wg.Wait()
+
+	assert.NoError(t, results[0])
+	assert.ErrorIs(t, results[1], errFailed)
+	assert.ErrorIs(t, results[2], context.Canceled)
+	assert.NoError(t, results[3])
+
+	var values []int
+	rows, err := db.Query("SELECT v FROM t ORDER BY v;")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		require.NoError(t, rows.Scan(&v))
+		values = append(values, v)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{0, 3}, values)
+}
+
+func Test_groupWriterClosed(t *testing.T) {
+	db := newWriterTestDB(t)
+
+	w := newGroupWriter(db, 0, 0)
+	w.close()
+	w.close()
+
+	var txs sync.Map
+	assert.ErrorIs(t, w.write(context.Background(), 1, insertValue(0, &txs)), errWriterClosed)
+}
+
+func Test_ExporterGroupCommit(t *testing.T) {
+	ctx := context.Background()
+	cfg := createDefaultConfig().(*Config)
+	cfg.Path = filepath.Join(t.TempDir(), "group.db")
+	cfg.MaxBatchDelay = 50 * time.Millisecond
+
+	ex, err := newSqliteExporter(cfg)
+	require.NoError(t, err)
+	require.NoError(t, ex.Start(ctx, componenttest.NewNopHost()))
+
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := ex.ConsumeTraces(ctx, benchmarkTraces(i, 10)); err != nil {
+				failed.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Zero(t, failed.Load())
+
+	assert.Equal(t, 100, countRows(t, ex.db, "spans"))
+	assert.Equal(t, 200, countRows(t, ex.db, "events"))
+	require.NoError(t, ex.Shutdown(ctx))
+}
+
+func BenchmarkConsumeTracesConcurrent(b *testing.B) {
+	const spansPerBatch = 100
+
+	for _, delay := range []time.Duration{-1, 0, time.Millisecond} {
+		name := fmt.Sprintf("max_batch_delay=%s", delay)
+		if delay < 0 {
+			name = "no_writer"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx := context.Background()
+			cfg := createDefaultConfig().(*Config)
+			cfg.Path = filepath.Join(b.TempDir(), "bench.db")
+			cfg.RowsPerStatement = 100
+			if delay >= 0 {
+				cfg.MaxBatchDelay = delay
+			}
+
+			ex, err := newSqliteExporter(cfg)
+			require.NoError(b, err)
+			defer ex.Shutdown(ctx)
+			if delay >= 0 {
+				ex.startWriter()
+			}
+
+			var seed atomic.Int64
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					traces := benchmarkTraces(int(seed.Add(1)), spansPerBatch)
+					if err := ex.ConsumeTraces(ctx, traces); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+			b.ReportMetric(float64(b.N*spansPerBatch)/b.Elapsed().Seconds(), "spans/s")
+		})
+	}
+}